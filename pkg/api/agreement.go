@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/schema"
+)
+
+type CreateAgreementRequest struct {
+	Reference      string `json:"reference"`
+	Description    string `json:"description"`
+	UserIdentifier string `json:"user_identifier,omitempty"`
+}
+
+type SearchAgreementsRequest struct {
+	Reference string `schema:"reference,omitempty"`
+	Status    string `schema:"status,omitempty"`
+}
+
+type AgreementSearchResults struct {
+	Total   int         `json:"total"`
+	Count   int         `json:"count"`
+	Page    int         `json:"page"`
+	Results Agreements  `json:"results"`
+	Links   SearchLinks `json:"_links"`
+}
+
+func (client *Client) CreateAgreement(request CreateAgreementRequest) (Agreement, error) {
+	var agreement Agreement
+
+	defaultValues := CreateAgreementRequest{
+		Reference:   uuid.New().String(),
+		Description: fmt.Sprintf("Pay CLI generated agreement %s", time.Now().Format(time.Stamp)),
+	}
+	err := Replace(defaultValues, &request)
+	if err != nil {
+		return agreement, err
+	}
+
+	err = client.Post("v1/agreements", request, &agreement)
+	if err != nil {
+		return agreement, err
+	}
+	agreement.furnishToolboxURL(client.Environment)
+	return agreement, nil
+}
+
+func (client *Client) GetAgreement(id string) (Agreement, error) {
+	var agreement Agreement
+
+	if strings.TrimSpace(id) == "" {
+		return agreement, errors.New("Invalid agreement ID provided, unable to get agreement")
+	}
+
+	err := client.Get("v1/agreements/"+id, &agreement)
+	if err != nil {
+		return agreement, err
+	}
+	agreement.furnishToolboxURL(client.Environment)
+	return agreement, nil
+}
+
+// CancelAgreement cancels an active agreement and returns the agreement in its resulting state
+func (client *Client) CancelAgreement(id string) (Agreement, error) {
+	if strings.TrimSpace(id) == "" {
+		return Agreement{}, errors.New("Invalid agreement ID provided, unable to cancel agreement")
+	}
+
+	err := client.Post(fmt.Sprintf("v1/agreements/%s/cancel", id), nil, nil)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == 400 {
+			return Agreement{}, fmt.Errorf("Agreement %s cannot be cancelled, only active agreements can be cancelled: %w", id, err)
+		}
+		return Agreement{}, err
+	}
+	return client.GetAgreement(id)
+}
+
+// SearchAgreements returns all agreements matching the request, following the next page links until
+// every page has been fetched or the limit has been reached (a limit of 0 fetches everything)
+func (client *Client) SearchAgreements(request SearchAgreementsRequest, limit int) (Agreements, error) {
+	var agreements Agreements
+
+	query, err := request.format()
+	if err != nil {
+		return agreements, err
+	}
+
+	pageURL := "v1/agreements?" + query.Encode()
+	for pageURL != "" {
+		var results AgreementSearchResults
+		err := client.Get(pageURL, &results)
+		if err != nil {
+			return agreements, err
+		}
+		for _, agreement := range results.Results {
+			agreement.furnishToolboxURL(client.Environment)
+			agreements = append(agreements, agreement)
+			if limit > 0 && len(agreements) >= limit {
+				return agreements, nil
+			}
+		}
+		pageURL = results.Links.NextPage.Href
+	}
+	return agreements, nil
+}
+
+func (searchRequest *SearchAgreementsRequest) format() (url.Values, error) {
+	encoder := schema.NewEncoder()
+	query := url.Values{}
+	err := encoder.Encode(searchRequest, query)
+	return query, err
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/alphagov/pay-cli/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Agreements", func() {
+	var server *httptest.Server
+	var client *Client
+	var requests []map[string]interface{}
+
+	BeforeEach(func() {
+		requests = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var request map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&request)
+			requests = append(requests, request)
+
+			switch r.URL.Path {
+			case "/v1/payments":
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprint(w, `{"payment_id": "p1", "state": {"status": "created", "finished": false}}`)
+			case "/v1/agreements":
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"agreement_id": "a1", "reference": "%s", "status": "created"}`, request["reference"])
+			case "/v1/agreements/a1/cancel":
+				w.WriteHeader(http.StatusNoContent)
+			case "/v1/agreements/created/cancel":
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"code": "P2495", "description": "Agreement must be active to be cancelled"}`)
+			case "/v1/agreements/a1":
+				fmt.Fprint(w, `{"agreement_id": "a1", "status": "cancelled"}`)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		client = NewClient(config.Environment{APIKey: "api_test_key", BaseURL: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Taking a payment with authorisation mode agreement", func() {
+		Specify("An agreement ID should be required", func() {
+			_, err := client.CreatePayment(CreatePaymentRequest{AuthorisationMode: AuthorisationModeAgreement, AgreementID: " "})
+			Expect(err).Should(MatchError("An agreement ID (--agreement-id) is required to take a payment with authorisation mode agreement"))
+			Expect(requests).Should(BeEmpty())
+		})
+
+		Specify("A return URL should be rejected", func() {
+			_, err := client.CreatePayment(CreatePaymentRequest{AuthorisationMode: AuthorisationModeAgreement, AgreementID: "a1", ReturnURL: "https://example.org"})
+			Expect(err).Should(MatchError("A return URL cannot be used with authorisation mode agreement"))
+			Expect(requests).Should(BeEmpty())
+		})
+
+		Specify("The default return URL should not be sent", func() {
+			_, err := client.CreatePayment(CreatePaymentRequest{AuthorisationMode: AuthorisationModeAgreement, AgreementID: "a1"})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(requests).Should(HaveLen(1))
+			Expect(requests[0]).ShouldNot(HaveKey("return_url"))
+			Expect(requests[0]).Should(HaveKeyWithValue("agreement_id", "a1"))
+		})
+	})
+
+	Specify("Creating an agreement should fill in a reference and description", func() {
+		agreement, err := client.CreateAgreement(CreateAgreementRequest{UserIdentifier: "user"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(agreement.Reference).Should(HaveLen(36))
+		Expect(requests[0]).Should(HaveKeyWithValue("description", HavePrefix("Pay CLI generated agreement")))
+		Expect(requests[0]).Should(HaveKeyWithValue("user_identifier", "user"))
+	})
+
+	Context("Cancelling an agreement", func() {
+		Specify("The cancelled agreement should be returned", func() {
+			agreement, err := client.CancelAgreement("a1")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(agreement.Status).Should(Equal("cancelled"))
+		})
+
+		Specify("Agreements that aren't active should fail with the reason", func() {
+			_, err := client.CancelAgreement("created")
+			Expect(err).Should(MatchError(HavePrefix("Agreement created cannot be cancelled, only active agreements can be cancelled")))
+		})
+
+		Specify("An empty agreement ID should fail without calling the API", func() {
+			_, err := client.CancelAgreement(" ")
+			Expect(err).Should(MatchError("Invalid agreement ID provided, unable to cancel agreement"))
+			Expect(requests).Should(BeEmpty())
+		})
+	})
+})
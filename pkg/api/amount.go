@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Amount is a value in pence, tables show it in pounds next to the pence value and CSV keeps it in pence
+type Amount int
+
+var poundsPattern = regexp.MustCompile(`^(?:£\s*(\d+)(?:\.(\d{1,2}))?|(\d+)(?:\.(\d{1,2}))?\s*GBP)$`)
+
+var pencePattern = regexp.MustCompile(`(?i)^(\d+)\s*p$`)
+
+// ParseAmount converts a human amount into pence. The unit must be stated so there is no doubt whether
+// a number is pounds or pence: £12.50, 12.50GBP and 1250p are all accepted, 1250 and 12.50 are not
+func ParseAmount(input string) (int, error) {
+	value := strings.TrimSpace(input)
+	if value == "" {
+		return 0, fmt.Errorf("Amount is required, use pounds (£12.50 or 12.50GBP) or pence (1250p)")
+	}
+
+	if match := pencePattern.FindStringSubmatch(value); match != nil {
+		return strconv.Atoi(match[1])
+	}
+
+	if match := poundsPattern.FindStringSubmatch(strings.ToUpper(value)); match != nil {
+		pounds, fraction := match[1], match[2]
+		if pounds == "" {
+			pounds, fraction = match[3], match[4]
+		}
+		if len(fraction) == 1 {
+			fraction += "0"
+		}
+		if fraction == "" {
+			fraction = "00"
+		}
+		return strconv.Atoi(pounds + fraction)
+	}
+
+	return 0, fmt.Errorf("Invalid amount %s, state the unit to avoid ambiguity: pounds (£12.50 or 12.50GBP) or pence (1250p)", input)
+}
+
+// FormatAmount formats pence as pounds, e.g. £12.50
+func FormatAmount(pence int) string {
+	sign := ""
+	if pence < 0 {
+		sign = "-"
+		pence = -pence
+	}
+	return fmt.Sprintf("%s£%d.%02d", sign, pence/100, pence%100)
+}
+
+func (amount Amount) String() string {
+	return fmt.Sprintf("%s (%dp)", FormatAmount(int(amount)), int(amount))
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Amount", func() {
+	Context("Parsing amounts with an explicit unit", func() {
+		Specify("Pounds and pence should be converted to pence", func() {
+			for input, expected := range map[string]int{
+				"£12.50":   1250,
+				"£12.5":    1250,
+				"£12":      1200,
+				"£0.05":    5,
+				"12.50GBP": 1250,
+				"12.50gbp": 1250,
+				"20 GBP":   2000,
+				"1250p":    1250,
+				"1250P":    1250,
+				" 1250p ":  1250,
+				"0p":       0,
+			} {
+				Expect(ParseAmount(input)).Should(Equal(expected), input)
+			}
+		})
+
+		Specify("Amounts without a unit or with an ambiguous value should be rejected", func() {
+			for _, input := range []string{"", "1250", "12.50", "£12.505", "12.5p", "£12p", "£1,250", "-£5", "£", "p", "12.50 USD"} {
+				_, err := ParseAmount(input)
+				Expect(err).Should(HaveOccurred(), input)
+			}
+		})
+	})
+
+	Context("Formatting amounts", func() {
+		Specify("Pence should be formatted as pounds", func() {
+			Expect(FormatAmount(1250)).Should(Equal("£12.50"))
+			Expect(FormatAmount(5)).Should(Equal("£0.05"))
+			Expect(FormatAmount(-200)).Should(Equal("-£2.00"))
+			Expect(Amount(2000).String()).Should(Equal("£20.00 (2000p)"))
+		})
+	})
+})
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/common"
+	"github.com/google/uuid"
+	"github.com/jedib0t/go-pretty/table"
+)
+
+type BulkCreateOptions struct {
+	Count       int
+	Concurrency int
+
+	// RatePerSecond limits how many payments are created each second across all workers, 0 is unlimited
+	RatePerSecond float64
+
+	// OnCreated is called as each payment is created, calls are never concurrent
+	OnCreated func(Payment)
+}
+
+// ReferenceTemplateData is available to templated references, e.g. --reference 'perf-{{.Index}}'
+type ReferenceTemplateData struct {
+	Index int
+	UUID  string
+}
+
+type BulkCreateResult struct {
+	Requested int
+	Created   int
+	Failures  map[string]int
+	Latencies []time.Duration
+	Elapsed   time.Duration
+}
+
+// BulkCreatePayments creates options.Count payments from the same request using a pool of workers. A
+// reference containing a template is rendered for each payment with ReferenceTemplateData
+func (client *Client) BulkCreatePayments(request CreatePaymentRequest, options BulkCreateOptions) (BulkCreateResult, error) {
+	result := BulkCreateResult{
+		Requested: options.Count,
+		Failures:  make(map[string]int),
+	}
+	if options.Count < 1 {
+		return result, errors.New("Count must be at least 1 to create payments")
+	}
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+
+	referenceTemplate, err := parseReferenceTemplate(request.Reference)
+	if err != nil {
+		return result, err
+	}
+
+	var throttle <-chan time.Time
+	if options.RatePerSecond > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / options.RatePerSecond))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	indexes := make(chan int)
+	var mutex sync.Mutex
+	var workers sync.WaitGroup
+	start := time.Now()
+
+	for worker := 0; worker < options.Concurrency; worker++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for index := range indexes {
+				paymentRequest := request
+				if referenceTemplate != nil {
+					reference, err := renderReference(referenceTemplate, index)
+					if err != nil {
+						mutex.Lock()
+						result.Failures["invalid reference template"]++
+						mutex.Unlock()
+						continue
+					}
+					paymentRequest.Reference = reference
+				}
+
+				requestStart := time.Now()
+				payment, err := client.CreatePayment(paymentRequest)
+				latency := time.Since(requestStart)
+
+				mutex.Lock()
+				if err != nil {
+					result.Failures[failureCode(err)]++
+				} else {
+					result.Created++
+					result.Latencies = append(result.Latencies, latency)
+					if options.OnCreated != nil {
+						options.OnCreated(payment)
+					}
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for index := 1; index <= options.Count; index++ {
+		if throttle != nil && index > 1 {
+			<-throttle
+		}
+		indexes <- index
+	}
+	close(indexes)
+	workers.Wait()
+
+	result.Elapsed = time.Since(start)
+	return result, nil
+}
+
+// failureCode groups errors by their Pay error code where the API returned one
+func failureCode(err error) string {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Code != "" {
+			return fmt.Sprintf("%s (%d)", apiErr.Code, apiErr.StatusCode)
+		}
+		return fmt.Sprintf("HTTP %d", apiErr.StatusCode)
+	}
+	return "connection error"
+}
+
+// Summarise writes the outcome, latency percentiles and failures broken down by error code
+func (result *BulkCreateResult) Summarise(out io.Writer) {
+	t := table.NewWriter()
+	t.SetOutputMirror(out)
+	t.SetTitle("Created payments")
+	t.AppendRows([]table.Row{
+		{"Requested", result.Requested},
+		{"Created", result.Created},
+		{"Failed", result.Requested - result.Created},
+		{"Elapsed", result.Elapsed.Round(time.Millisecond)},
+		{"Throughput", fmt.Sprintf("%.2f/s", float64(result.Created)/result.Elapsed.Seconds())},
+		{"p50 latency", common.Percentile(result.Latencies, 50).Round(time.Millisecond)},
+		{"p95 latency", common.Percentile(result.Latencies, 95).Round(time.Millisecond)},
+		{"p99 latency", common.Percentile(result.Latencies, 99).Round(time.Millisecond)},
+	})
+	t.Render()
+
+	if len(result.Failures) == 0 {
+		return
+	}
+	codes := make([]string, 0, len(result.Failures))
+	for code := range result.Failures {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	failures := table.NewWriter()
+	failures.SetOutputMirror(out)
+	failures.AppendHeader(table.Row{"Error", "Count"})
+	for _, code := range codes {
+		failures.AppendRow(table.Row{code, result.Failures[code]})
+	}
+	failures.Render()
+}
+
+// RenderReference renders a templated reference as the payment at index would be in a bulk run, so a
+// single payment gets the same reference as the first of many. References without a template are
+// returned unchanged
+func RenderReference(reference string, index int) (string, error) {
+	referenceTemplate, err := parseReferenceTemplate(reference)
+	if err != nil || referenceTemplate == nil {
+		return reference, err
+	}
+	rendered, err := renderReference(referenceTemplate, index)
+	if err != nil {
+		return reference, fmt.Errorf("Invalid reference template: %w", err)
+	}
+	return rendered, nil
+}
+
+// parseReferenceTemplate returns nil if the reference isn't templated
+func parseReferenceTemplate(reference string) (*template.Template, error) {
+	if !strings.Contains(reference, "{{") {
+		return nil, nil
+	}
+	parsed, err := template.New("reference").Option("missingkey=error").Parse(reference)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid reference template: %w", err)
+	}
+	return parsed, nil
+}
+
+func renderReference(referenceTemplate *template.Template, index int) (string, error) {
+	var reference bytes.Buffer
+	err := referenceTemplate.Execute(&reference, ReferenceTemplateData{Index: index, UUID: uuid.New().String()})
+	return reference.String(), err
+}
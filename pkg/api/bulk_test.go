@@ -0,0 +1,115 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Bulk creating payments", func() {
+	var server *httptest.Server
+	var client *Client
+	var mutex sync.Mutex
+	var references []string
+
+	BeforeEach(func() {
+		references = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var request CreatePaymentRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			mutex.Lock()
+			references = append(references, request.Reference)
+			mutex.Unlock()
+
+			// references ending in 3 are rejected to check failures are grouped
+			if strings.HasSuffix(request.Reference, "3") {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				w.Write([]byte(`{"field": "reference", "code": "P0102", "description": "Invalid attribute value: reference"}`))
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"payment_id": "%s", "reference": "%s", "state": {"status": "created", "finished": false}}`, request.Reference, request.Reference)
+		}))
+		client = NewClient(config.Environment{APIKey: "api_test_key", BaseURL: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Specify("Payments should be created by a pool of workers with failures grouped by code", func() {
+		var created []string
+		result, err := client.BulkCreatePayments(CreatePaymentRequest{Reference: "perf-{{.Index}}"}, BulkCreateOptions{
+			Count:       25,
+			Concurrency: 4,
+			OnCreated: func(payment Payment) {
+				created = append(created, payment.ID)
+			},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Requested).Should(Equal(25))
+
+		// perf-3, perf-13 and perf-23 are rejected
+		Expect(result.Created).Should(Equal(22))
+		Expect(created).Should(HaveLen(22))
+		Expect(result.Latencies).Should(HaveLen(22))
+		Expect(result.Failures).Should(Equal(map[string]int{"P0102 (422)": 3}))
+
+		unique := make(map[string]bool)
+		for _, reference := range references {
+			unique[reference] = true
+		}
+		Expect(references).Should(HaveLen(25))
+		Expect(unique).Should(HaveLen(25))
+		Expect(unique).Should(HaveKey("perf-25"))
+	})
+
+	Specify("UUID references should be unique", func() {
+		result, err := client.BulkCreatePayments(CreatePaymentRequest{Reference: "{{.UUID}}"}, BulkCreateOptions{Count: 10, Concurrency: 3})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Created + result.Failures["P0102 (422)"]).Should(Equal(10))
+
+		unique := make(map[string]bool)
+		for _, reference := range references {
+			Expect(reference).Should(HaveLen(36))
+			unique[reference] = true
+		}
+		Expect(unique).Should(HaveLen(10))
+	})
+
+	Specify("An invalid reference template should be rejected before creating payments", func() {
+		_, err := client.BulkCreatePayments(CreatePaymentRequest{Reference: "perf-{{.Index"}, BulkCreateOptions{Count: 2})
+		Expect(err).Should(MatchError(HavePrefix("Invalid reference template")))
+		Expect(references).Should(BeEmpty())
+	})
+
+	Specify("A single payment should render its reference as the first of a bulk run", func() {
+		Expect(RenderReference("perf-{{.Index}}", 1)).Should(Equal("perf-1"))
+		Expect(RenderReference("plain", 1)).Should(Equal("plain"))
+
+		_, err := RenderReference("{{.Missing}}", 1)
+		Expect(err).Should(MatchError(HavePrefix("Invalid reference template")))
+	})
+
+	Specify("The rate should limit how quickly payments are created", func() {
+		start := time.Now()
+		result, err := client.BulkCreatePayments(CreatePaymentRequest{Reference: "rate"}, BulkCreateOptions{
+			Count:         5,
+			Concurrency:   5,
+			RatePerSecond: 50,
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Created).Should(Equal(5))
+
+		// the first payment isn't throttled, the other 4 wait 20ms each
+		Expect(time.Since(start)).Should(BeNumerically(">=", 80*time.Millisecond))
+	})
+})
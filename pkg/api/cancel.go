@@ -0,0 +1,20 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CancelPayment cancels a payment that has not yet finished and returns the payment in its resulting state
+func (client *Client) CancelPayment(id string) (Payment, error) {
+	if strings.TrimSpace(id) == "" {
+		return Payment{}, errors.New("Invalid payment ID provided, unable to cancel payment")
+	}
+
+	err := client.Post(fmt.Sprintf("v1/payments/%s/cancel", id), nil, nil)
+	if err != nil {
+		return Payment{}, client.explainStateConflict(err, id, "cancelled", "only payments that have not finished (created, started, submitted or capturable) can be cancelled")
+	}
+	return client.GetPayment(id)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CapturePayment captures a delayed capture payment and returns the payment in its resulting state
+func (client *Client) CapturePayment(id string) (Payment, error) {
+	if strings.TrimSpace(id) == "" {
+		return Payment{}, errors.New("Invalid payment ID provided, unable to capture payment")
+	}
+
+	err := client.Post(fmt.Sprintf("v1/payments/%s/capture", id), nil, nil)
+	if err != nil {
+		return Payment{}, client.explainStateConflict(err, id, "captured", "only payments created with delayed capture that have been authorised (state `capturable`) can be captured")
+	}
+	return client.GetPayment(id)
+}
+
+// explainStateConflict fetches the current state of a payment to explain why an action was rejected
+func (client *Client) explainStateConflict(err error, id string, action string, rule string) error {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusConflict) {
+		return err
+	}
+	payment, getErr := client.GetPayment(id)
+	if getErr != nil || payment.State.Status == "" {
+		return fmt.Errorf("Payment %s cannot be %s, %s: %w", id, action, rule, err)
+	}
+	return fmt.Errorf("Payment %s cannot be %s while it is in state `%s`, %s: %w", id, action, payment.State.Status, rule, err)
+}
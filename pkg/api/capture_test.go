@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/alphagov/pay-cli/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Capturing and cancelling payments", func() {
+	var server *httptest.Server
+	var client *Client
+
+	BeforeEach(func() {
+		// payment IDs are named after their state, capturing a capturable payment succeeds
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+			id := path[2]
+			switch {
+			case r.Method == "GET" && id == "missing":
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"code": "P0200", "description": "Not found"}`)
+			case r.Method == "GET":
+				fmt.Fprintf(w, `{"payment_id": "%s", "state": {"status": "%s", "finished": false}}`, id, id)
+			case id == "capturable":
+				w.WriteHeader(http.StatusNoContent)
+			case id == "started":
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, `{"code": "P0502", "description": "Cancellation of payment failed"}`)
+			case id == "forbidden":
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"code": "P0900", "description": "Credentials are required to access this resource"}`)
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"code": "P1003", "description": "Capture of payment failed"}`)
+			}
+		}))
+		client = NewClient(config.Environment{APIKey: "api_test_key", BaseURL: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Specify("Capturing should return the payment in its resulting state", func() {
+		payment, err := client.CapturePayment("capturable")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payment.ID).Should(Equal("capturable"))
+	})
+
+	Specify("A rejected capture should explain the state the payment is in", func() {
+		_, err := client.CapturePayment("created")
+		Expect(err).Should(MatchError(HavePrefix("Payment created cannot be captured while it is in state `created`, only payments created with delayed capture that have been authorised (state `capturable`) can be captured")))
+
+		var apiErr *Error
+		Expect(errors.As(err, &apiErr)).Should(BeTrue())
+		Expect(apiErr.Code).Should(Equal("P1003"))
+	})
+
+	Specify("A conflicting cancel should explain the state the payment is in", func() {
+		_, err := client.CancelPayment("started")
+		Expect(err).Should(MatchError(HavePrefix("Payment started cannot be cancelled while it is in state `started`")))
+	})
+
+	Specify("The rule should still be given when the payment can't be fetched", func() {
+		_, err := client.CapturePayment("missing")
+		Expect(err).Should(MatchError(HavePrefix("Payment missing cannot be captured, only payments created with delayed capture")))
+	})
+
+	Specify("Other errors should be returned unchanged", func() {
+		_, err := client.CapturePayment("forbidden")
+		Expect(err).Should(MatchError(HavePrefix("GOV.UK Pay API returned 401")))
+		Expect(err).Should(BeAssignableToTypeOf(&Error{}))
+	})
+})
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/common"
+	"github.com/alphagov/pay-cli/pkg/config"
+	"github.com/google/uuid"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const UserAgent = "pay-cli (+https://github.com/alphagov/pay-cli)"
+
+const DefaultTimeout = 30 * time.Second
+
+// Client makes authenticated requests to the GOV.UK Pay public API for a configured environment,
+// transient failures are retried up to Retries times
+type Client struct {
+	Environment config.Environment
+	HTTPClient  *http.Client
+	Retries     int
+
+	// DryRun prints requests that would change something as curl commands instead of sending them,
+	// GET requests are still sent so that commands can check the current state of a payment
+	DryRun bool
+}
+
+// Error is a non-success response from the API, decoded from the standard GOV.UK Pay error body
+type Error struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"code"`
+	Field       string `json:"field,omitempty"`
+	Description string `json:"description"`
+}
+
+// NewClient returns a client for the environment, the environment must already be initialised
+func NewClient(environment config.Environment) *Client {
+	return &Client{
+		Environment: environment,
+		HTTPClient: &http.Client{
+			Timeout:   DefaultTimeout,
+			Transport: common.TraceTransport(nil),
+		},
+		Retries: DefaultRetries,
+	}
+}
+
+// URL resolves a path against the public API for the environment, absolute URLs (such as
+// pagination links returned by the API) are used as they are
+func (client *Client) URL(path string) string {
+	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") {
+		return path
+	}
+	return fmt.Sprintf("%s/%s", client.Environment.PublicAPIURL(), strings.TrimPrefix(path, "/"))
+}
+
+// Get decodes the response of a GET request into result
+func (client *Client) Get(path string, result interface{}) error {
+	return client.Do("GET", path, nil, result)
+}
+
+// Post sends body as JSON and decodes the response into result, either may be nil
+func (client *Client) Post(path string, body interface{}, result interface{}) error {
+	return client.Do("POST", path, body, result)
+}
+
+// Do sends a request to the API, non-2xx responses are returned as an *Error. POST requests carry an
+// Idempotency-Key that is kept across retries so a retried request can never be actioned twice
+func (client *Client) Do(method string, path string, body interface{}, result interface{}) error {
+	var payload []byte
+	if body != nil {
+		jsonBytes, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = jsonBytes
+	}
+
+	var idempotencyKey string
+	if method == "POST" {
+		idempotencyKey = uuid.New().String()
+	}
+
+	if client.DryRun && method != "GET" {
+		req, err := client.newRequest(method, path, payload, idempotencyKey)
+		if err != nil {
+			return err
+		}
+		fmt.Println(CurlCommand(req, payload))
+		return ErrDryRun
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := client.newRequest(method, path, payload, idempotencyKey)
+		if err != nil {
+			return err
+		}
+
+		res, err := client.HTTPClient.Do(req)
+		if err == nil && !isRetryableStatus(res.StatusCode) {
+			return decodeResponse(res, result)
+		}
+
+		if attempt >= client.Retries {
+			if err != nil {
+				return err
+			}
+			return decodeResponse(res, result)
+		}
+
+		delay := retryDelay(attempt, res)
+		if err != nil {
+			log.Warnf("%s %s failed (%s), retrying in %s", method, req.URL.Path, err, delay.Round(time.Millisecond))
+		} else {
+			log.Warnf("%s %s returned %d, retrying in %s", method, req.URL.Path, res.StatusCode, delay.Round(time.Millisecond))
+			res.Body.Close()
+		}
+		time.Sleep(delay)
+	}
+}
+
+func (client *Client) newRequest(method string, path string, payload []byte, idempotencyKey string) (*http.Request, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequest(method, client.URL(path), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("accept", "application/json")
+	req.Header.Add("content-type", "application/json")
+	req.Header.Add("authorization", fmt.Sprintf("Bearer %s", client.Environment.APIKey))
+	req.Header.Add("user-agent", UserAgent)
+	if idempotencyKey != "" {
+		req.Header.Add("idempotency-key", idempotencyKey)
+	}
+	return req, nil
+}
+
+func decodeResponse(res *http.Response, result interface{}) error {
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return parseError(res)
+	}
+
+	if result == nil || res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+func parseError(res *http.Response) error {
+	apiErr := &Error{StatusCode: res.StatusCode}
+	responseBody, _ := ioutil.ReadAll(res.Body)
+
+	// errors from the load balancer or a proxy won't be in the standard format
+	if json.Unmarshal(responseBody, apiErr) != nil || apiErr.Description == "" {
+		apiErr.Description = http.StatusText(res.StatusCode)
+	}
+	return apiErr
+}
+
+func (apiErr *Error) Error() string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("GOV.UK Pay API returned %d", apiErr.StatusCode))
+	if apiErr.Code != "" {
+		builder.WriteString(" " + apiErr.Code)
+	}
+	builder.WriteString(": " + apiErr.Description)
+	if apiErr.Field != "" {
+		builder.WriteString(fmt.Sprintf(" (field: %s)", apiErr.Field))
+	}
+	return builder.String()
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var server *httptest.Server
+	var client *Client
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/payments/valid":
+				w.Write([]byte(`{"payment_id": "valid", "amount": 2000, "state": {"status": "created", "finished": false}}`))
+			case "/v1/payments/invalid":
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"field": "amount", "code": "P0102", "description": "Invalid attribute value: amount"}`))
+			default:
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte(`<html>Bad Gateway</html>`))
+			}
+		}))
+		client = NewClient(config.Environment{APIKey: "api_test_key", BaseURL: "example.com"})
+		retryBaseDelay = time.Millisecond
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Decoding responses", func() {
+		Specify("A successful response should be decoded into the result", func() {
+			var payment Payment
+			Expect(client.Get(server.URL+"/v1/payments/valid", &payment)).Should(Succeed())
+			Expect(payment.ID).Should(Equal("valid"))
+			Expect(payment.State.Status).Should(Equal("created"))
+		})
+
+		Specify("A Pay error response should be decoded into a typed error", func() {
+			err := client.Get(server.URL+"/v1/payments/invalid", nil)
+			Expect(err).Should(Equal(&Error{StatusCode: 400, Code: "P0102", Field: "amount", Description: "Invalid attribute value: amount"}))
+			Expect(err).Should(MatchError("GOV.UK Pay API returned 400 P0102: Invalid attribute value: amount (field: amount)"))
+		})
+
+		Specify("A non-Pay error response should fall back to the HTTP status text", func() {
+			err := client.Get(server.URL+"/unknown", nil)
+			Expect(err).Should(MatchError("GOV.UK Pay API returned 502: Bad Gateway"))
+		})
+	})
+
+	Context("Retrying transient failures", func() {
+		var attempts int
+		var idempotencyKeys []string
+
+		BeforeEach(func() {
+			attempts = 0
+			idempotencyKeys = nil
+			server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				idempotencyKeys = append(idempotencyKeys, r.Header.Get("Idempotency-Key"))
+				if attempts < 3 {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"payment_id": "created"}`))
+			})
+		})
+
+		Specify("A POST should be retried with the same idempotency key until it succeeds", func() {
+			var payment Payment
+			Expect(client.Post(server.URL+"/v1/payments", CreatePaymentRequest{Amount: 100}, &payment)).Should(Succeed())
+			Expect(payment.ID).Should(Equal("created"))
+			Expect(attempts).Should(Equal(3))
+			Expect(idempotencyKeys[0]).ShouldNot(BeEmpty())
+			Expect(idempotencyKeys).Should(Equal([]string{idempotencyKeys[0], idempotencyKeys[0], idempotencyKeys[0]}))
+		})
+
+		Specify("The last failure should be returned once retries are exhausted", func() {
+			client.Retries = 1
+			err := client.Get(server.URL+"/v1/payments/valid", nil)
+			Expect(err).Should(MatchError("GOV.UK Pay API returned 503: Service Unavailable"))
+			Expect(attempts).Should(Equal(2))
+			Expect(idempotencyKeys).Should(Equal([]string{"", ""}))
+		})
+	})
+
+	Context("Describing requests as curl commands", func() {
+		Specify("The bearer token should be redacted and the body quoted for the shell", func() {
+			payload := []byte(`{"reference":"it's mine"}`)
+			req, err := client.newRequest("POST", "v1/payments", payload, "key")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			command := CurlCommand(req, payload)
+			Expect(command).Should(HavePrefix("curl -X POST 'https://publicapi.example.com/v1/payments'"))
+			Expect(command).Should(ContainSubstring(`-H "authorization: Bearer ${PAY_API_KEY}"`))
+			Expect(command).Should(ContainSubstring(`-H 'idempotency-key: key'`))
+			Expect(command).Should(HaveSuffix(`--data '{"reference":"it'\''s mine"}'`))
+			Expect(command).ShouldNot(ContainSubstring("api_test_key"))
+		})
+	})
+
+	Context("Resolving URLs", func() {
+		Specify("Relative paths should resolve against the environment public API", func() {
+			Expect(client.URL("v1/payments")).Should(Equal("https://publicapi.example.com/v1/payments"))
+		})
+	})
+})
@@ -1,65 +1,123 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"net/http"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"time"
 
-	"github.com/alphagov/pay-cli/pkg/config"
 	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
 )
 
+type BillingAddress struct {
+	Line1    string `json:"line1,omitempty" yaml:"line1,omitempty"`
+	Line2    string `json:"line2,omitempty" yaml:"line2,omitempty"`
+	Postcode string `json:"postcode,omitempty" yaml:"postcode,omitempty"`
+	City     string `json:"city,omitempty" yaml:"city,omitempty"`
+	Country  string `json:"country,omitempty" yaml:"country,omitempty"`
+}
+
+type PrefilledCardholderDetails struct {
+	CardholderName string          `json:"cardholder_name,omitempty" yaml:"cardholder_name,omitempty"`
+	BillingAddress *BillingAddress `json:"billing_address,omitempty" yaml:"billing_address,omitempty"`
+}
+
 type CreatePaymentRequest struct {
-	Amount      int    `json:"amount"`
-	Reference   string `json:"reference"`
-	Description string `json:"description"`
-	ReturnURL   string `json:"return_url"`
-	Language    string `json:"language"`
+	Amount                     int                         `json:"amount" yaml:"amount"`
+	Reference                  string                      `json:"reference" yaml:"reference"`
+	Description                string                      `json:"description" yaml:"description"`
+	ReturnURL                  string                      `json:"return_url,omitempty" yaml:"return_url,omitempty"`
+	Language                   string                      `json:"language" yaml:"language"`
+	Email                      string                      `json:"email,omitempty" yaml:"email,omitempty"`
+	Metadata                   map[string]interface{}      `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Moto                       bool                        `json:"moto,omitempty" yaml:"moto,omitempty"`
+	DelayedCapture             bool                        `json:"delayed_capture,omitempty" yaml:"delayed_capture,omitempty"`
+	PrefilledCardholderDetails *PrefilledCardholderDetails `json:"prefilled_cardholder_details,omitempty" yaml:"prefilled_cardholder_details,omitempty"`
+	SetUpAgreement             string                      `json:"set_up_agreement,omitempty" yaml:"set_up_agreement,omitempty"`
+	AgreementID                string                      `json:"agreement_id,omitempty" yaml:"agreement_id,omitempty"`
+	AuthorisationMode          string                      `json:"authorisation_mode,omitempty" yaml:"authorisation_mode,omitempty"`
+}
+
+// ReadCreatePaymentRequest loads a create payment request body from a JSON or YAML file, the format
+// is chosen by the file extension
+func ReadCreatePaymentRequest(path string) (CreatePaymentRequest, error) {
+	var request CreatePaymentRequest
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return request, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(contents, &request)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&request)
+	}
+	if err != nil {
+		return request, fmt.Errorf("Unable to read payment request from %s: %w", path, err)
+	}
+	return request, nil
+}
+
+// ParseMetadata converts key=value pairs into the metadata object accepted by the API
+func ParseMetadata(pairs []string) (map[string]interface{}, error) {
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	metadata := make(map[string]interface{})
+	for _, pair := range pairs {
+		keyValue := strings.SplitN(pair, "=", 2)
+		if len(keyValue) != 2 || strings.TrimSpace(keyValue[0]) == "" {
+			return nil, fmt.Errorf("Invalid metadata %s, expected key=value", pair)
+		}
+		metadata[strings.TrimSpace(keyValue[0])] = keyValue[1]
+	}
+	return metadata, nil
 }
 
-func CreatePayment(environment config.Environment, request CreatePaymentRequest, shouldOutputNextURL bool) error {
-	target := "v1/payments"
-	url := fmt.Sprintf("https://publicapi.%s/%s", environment.BaseURL, target)
+// AuthorisationModeAgreement payments are taken against a saved agreement without the paying user, so
+// they have no return URL or next URL
+const AuthorisationModeAgreement = "agreement"
+
+func (client *Client) CreatePayment(request CreatePaymentRequest) (Payment, error) {
+	var payment Payment
+
 	defaultValues := CreatePaymentRequest{
 		Amount:      2000,
 		Reference:   uuid.New().String(),
 		Description: fmt.Sprintf("Pay CLI generated payment %s", time.Now().Format(time.Stamp)),
-		ReturnURL:   fmt.Sprintf("https://%s", environment.BaseURL),
+		ReturnURL:   client.Environment.ReturnURL(),
 		Language:    "en",
 	}
+	if request.AuthorisationMode == AuthorisationModeAgreement {
+		if strings.TrimSpace(request.AgreementID) == "" {
+			return payment, errors.New("An agreement ID (--agreement-id) is required to take a payment with authorisation mode agreement")
+		}
+		if request.ReturnURL != "" {
+			return payment, errors.New("A return URL cannot be used with authorisation mode agreement")
+		}
+		defaultValues.ReturnURL = ""
+	}
 	err := Replace(defaultValues, &request)
 	if err != nil {
-		return err
+		return payment, err
 	}
-	payload := strings.NewReader(request.format())
-	req, _ := http.NewRequest("POST", url, payload)
 
-	req.Header.Add("content-type", "application/json")
-	req.Header.Add("authorization", fmt.Sprintf("Bearer %s", environment.APIKey))
-
-	res, err := http.DefaultClient.Do(req)
+	err = client.Post("v1/payments", request, &payment)
 	if err != nil {
-		return err
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != 201 {
-		return fmt.Errorf("Create payment request returned non-success code %d", res.StatusCode)
+		return payment, err
 	}
-
-	var payment Payment
-	payment.parse(res)
-	payment.furnishToolboxURL(environment)
-	payment.ChainOut(shouldOutputNextURL)
-	return nil
-}
-
-func (paymentRequest *CreatePaymentRequest) format() string {
-	result, _ := json.Marshal(paymentRequest)
-	return string(result)
+	payment.furnishToolboxURL(client.Environment)
+	return payment, nil
 }
 
 func IsZeroOfUnderlyingType(x interface{}) bool {
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Creating payments", func() {
+	Context("Reading a request from a file", func() {
+		var directory string
+
+		BeforeEach(func() {
+			var err error
+			directory, err = ioutil.TempDir("", "pay-cli")
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(directory)
+		})
+
+		write := func(name string, contents string) string {
+			path := filepath.Join(directory, name)
+			Expect(ioutil.WriteFile(path, []byte(contents), 0600)).Should(Succeed())
+			return path
+		}
+
+		Specify("JSON and YAML files should be read by their extension", func() {
+			request, err := ReadCreatePaymentRequest(write("request.json", `{"amount": 1250, "reference": "json", "metadata": {"ledger_code": 123}}`))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(request.Amount).Should(Equal(1250))
+			Expect(request.Reference).Should(Equal("json"))
+			Expect(request.Metadata).Should(HaveKeyWithValue("ledger_code", BeNumerically("==", 123)))
+
+			request, err = ReadCreatePaymentRequest(write("request.yml", "amount: 500\nreference: yaml\nprefilled_cardholder_details:\n  billing_address:\n    city: London\n"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(request.Amount).Should(Equal(500))
+			Expect(request.PrefilledCardholderDetails.BillingAddress.City).Should(Equal("London"))
+		})
+
+		Specify("Unknown fields should be rejected", func() {
+			_, err := ReadCreatePaymentRequest(write("request.json", `{"amount": 1250, "refrence": "typo"}`))
+			Expect(err).Should(MatchError(ContainSubstring(`unknown field "refrence"`)))
+
+			_, err = ReadCreatePaymentRequest(write("request.yaml", "amount: 1250\nrefrence: typo\n"))
+			Expect(err).Should(MatchError(ContainSubstring("field refrence not found")))
+		})
+
+		Specify("A missing file should fail", func() {
+			_, err := ReadCreatePaymentRequest(filepath.Join(directory, "missing.json"))
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("Parsing metadata", func() {
+		Specify("Key value pairs should be split on the first equals sign", func() {
+			metadata, err := ParseMetadata([]string{"ledger_code=AB100", " cost_centre =a=b", "empty="})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(metadata).Should(Equal(map[string]interface{}{"ledger_code": "AB100", "cost_centre": "a=b", "empty": ""}))
+		})
+
+		Specify("No pairs should leave metadata out of the request", func() {
+			Expect(ParseMetadata(nil)).Should(BeNil())
+		})
+
+		Specify("Pairs without a key or value should be rejected", func() {
+			for _, pair := range []string{"ledger_code", "=AB100", " =AB100"} {
+				_, err := ParseMetadata([]string{pair})
+				Expect(err).Should(MatchError("Invalid metadata "+pair+", expected key=value"), pair)
+			}
+		})
+	})
+
+	Context("Replacing unset values", func() {
+		Specify("Only zero values should be replaced", func() {
+			request := CreatePaymentRequest{Amount: 1250, Moto: true}
+			Expect(Replace(CreatePaymentRequest{Amount: 2000, Reference: "default", Language: "en"}, &request)).Should(Succeed())
+			Expect(request).Should(Equal(CreatePaymentRequest{Amount: 1250, Reference: "default", Language: "en", Moto: true}))
+		})
+	})
+})
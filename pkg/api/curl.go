@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrDryRun is returned in place of a response when a dry run stops a request that would change something
+var ErrDryRun = errors.New("dry run, request was not sent")
+
+// CurlCommand describes a request as a curl command that can be pasted into a shell, the bearer token is
+// replaced with a reference to $PAY_API_KEY so the command can be shared
+func CurlCommand(req *http.Request, payload []byte) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("curl -X %s %s", req.Method, shellQuote(req.URL.String())))
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range req.Header[name] {
+			header := fmt.Sprintf("%s: %s", strings.ToLower(name), value)
+			if strings.EqualFold(name, "authorization") {
+				builder.WriteString(" \\\n  -H \"authorization: Bearer ${PAY_API_KEY}\"")
+				continue
+			}
+			builder.WriteString(" \\\n  -H " + shellQuote(header))
+		}
+	}
+	if payload != nil {
+		builder.WriteString(" \\\n  --data " + shellQuote(string(payload)))
+	}
+	return builder.String()
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/url"
+
+	"github.com/gorilla/schema"
+)
+
+type SearchDisputesRequest struct {
+	Status          string `schema:"status,omitempty"`
+	FromDate        string `schema:"from_date,omitempty"`
+	ToDate          string `schema:"to_date,omitempty"`
+	FromSettledDate string `schema:"from_settled_date,omitempty"`
+	ToSettledDate   string `schema:"to_settled_date,omitempty"`
+}
+
+type DisputeSearchResults struct {
+	Total   int         `json:"total"`
+	Count   int         `json:"count"`
+	Page    int         `json:"page"`
+	Results Disputes    `json:"results"`
+	Links   SearchLinks `json:"_links"`
+}
+
+// SearchDisputes returns all disputes matching the request, following the next page links until
+// every page has been fetched or the limit has been reached (a limit of 0 fetches everything). The
+// API can't filter by payment so a payment ID is matched against each page as it is fetched
+func (client *Client) SearchDisputes(request SearchDisputesRequest, paymentID string, limit int) (Disputes, error) {
+	var disputes Disputes
+
+	query, err := request.format()
+	if err != nil {
+		return disputes, err
+	}
+
+	pageURL := "v1/disputes?" + query.Encode()
+	for pageURL != "" {
+		var results DisputeSearchResults
+		err := client.Get(pageURL, &results)
+		if err != nil {
+			return disputes, err
+		}
+		for _, dispute := range results.Results {
+			if paymentID != "" && dispute.PaymentID != paymentID {
+				continue
+			}
+			dispute.furnishToolboxURL(client.Environment)
+			disputes = append(disputes, dispute)
+			if limit > 0 && len(disputes) >= limit {
+				return disputes, nil
+			}
+		}
+		pageURL = results.Links.NextPage.Href
+	}
+	return disputes, nil
+}
+
+func (searchRequest *SearchDisputesRequest) format() (url.Values, error) {
+	encoder := schema.NewEncoder()
+	query := url.Values{}
+	err := encoder.Encode(searchRequest, query)
+	return query, err
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/alphagov/pay-cli/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Searching disputes", func() {
+	var server *httptest.Server
+	var client *Client
+	var pages int
+
+	BeforeEach(func() {
+		pages = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pages++
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprint(w, `{"page": 2, "results": [{"dispute_id": "d3", "payment_id": "p1"}], "_links": {}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"page": 1, "results": [{"dispute_id": "d1", "payment_id": "p1"}, {"dispute_id": "d2", "payment_id": "p2"}],
+				"_links": {"next_page": {"href": "%s/v1/disputes?page=2"}}}`, "http://"+r.Host)
+		}))
+		client = NewClient(config.Environment{APIKey: "api_test_key", BaseURL: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Specify("Disputes for a payment should be matched on every page and link to the payment in Toolbox", func() {
+		disputes, err := client.SearchDisputes(SearchDisputesRequest{}, "p1", 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(pages).Should(Equal(2))
+		Expect(disputes).Should(HaveLen(2))
+		Expect(disputes[0].ID).Should(Equal("d1"))
+		Expect(disputes[1].ID).Should(Equal("d3"))
+		Expect(disputes[1].Links.ToolboxURL.Href).Should(HaveSuffix("/transactions/p1"))
+	})
+
+	Specify("Searching should stop once the limit is reached", func() {
+		disputes, err := client.SearchDisputes(SearchDisputesRequest{}, "p1", 1)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(pages).Should(Equal(1))
+		Expect(disputes).Should(HaveLen(1))
+	})
+})
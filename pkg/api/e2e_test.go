@@ -0,0 +1,72 @@
+package api_test
+
+import (
+	"net/http/httptest"
+
+	"github.com/alphagov/pay-cli/pkg/api"
+	"github.com/alphagov/pay-cli/pkg/config"
+	"github.com/alphagov/pay-cli/pkg/mock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Using the API against the mock server", func() {
+	var server *httptest.Server
+	var client *api.Client
+
+	BeforeEach(func() {
+		server = httptest.NewServer(mock.NewServer())
+		client = api.NewClient(config.Environment{APIKey: "api_test_mock", BaseURL: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Specify("A created payment should be found by ID, by search and in its events", func() {
+		created, err := client.CreatePayment(api.CreatePaymentRequest{Amount: 1250, Reference: "e2e-reference"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(created.State.Status).Should(Equal("created"))
+		Expect(created.Links.NextURL.Href).Should(HavePrefix(server.URL + "/secure/"))
+		Expect(created.Links.ToolboxURL.Href).Should(Equal(server.URL + "/transactions/" + created.ID))
+
+		payment, err := client.GetPayment(created.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payment.Amount).Should(Equal(1250))
+		Expect(payment.ReturnURL).Should(Equal(server.URL))
+
+		payments, err := client.SearchPayments(api.SearchPaymentsRequest{Reference: "e2e-reference"}, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payments).Should(HaveLen(1))
+		Expect(payments[0].ID).Should(Equal(created.ID))
+
+		events, err := client.GetPaymentEvents(created.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(events.Events).Should(HaveLen(1))
+		Expect(events.Events[0].State.Status).Should(Equal("created"))
+	})
+
+	Specify("A payment that has not been taken should not be refundable", func() {
+		created, err := client.CreatePayment(api.CreatePaymentRequest{})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, err = client.PrepareRefund(created.ID, api.RefundCheck{Full: true})
+		Expect(err).Should(MatchError("Payment " + created.ID + " cannot be refunded yet, it is created and has not been captured"))
+	})
+
+	Specify("Cancelling a payment should finish it", func() {
+		created, err := client.CreatePayment(api.CreatePaymentRequest{})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		payment, err := client.CancelPayment(created.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payment.State.Status).Should(Equal("cancelled"))
+		Expect(payment.State.Finished).Should(BeTrue())
+	})
+
+	Specify("Requests without an API key should be rejected", func() {
+		client.Environment.APIKey = ""
+		_, err := client.GetPayment("unknown")
+		Expect(err).Should(MatchError("GOV.UK Pay API returned 401 P0920: Credentials are required to access this resource"))
+	})
+})
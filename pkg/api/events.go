@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/table"
+)
+
+type PaymentEvent struct {
+	PaymentID string       `json:"payment_id"`
+	State     PaymentState `json:"state"`
+	Updated   time.Time    `json:"updated"`
+}
+
+type PaymentEvents struct {
+	PaymentID string         `json:"payment_id"`
+	Events    []PaymentEvent `json:"events"`
+}
+
+func (client *Client) GetPaymentEvents(id string) (PaymentEvents, error) {
+	var events PaymentEvents
+
+	if strings.TrimSpace(id) == "" {
+		return events, errors.New("Invalid payment ID provided, unable to get payment events")
+	}
+
+	err := client.Get(fmt.Sprintf("v1/payments/%s/events", id), &events)
+	if err != nil {
+		return events, err
+	}
+
+	// the API makes no ordering guarantee, the timeline should always read oldest first
+	sort.SliceStable(events.Events, func(i, j int) bool {
+		return events.Events[i].Updated.Before(events.Events[j].Updated)
+	})
+	return events, nil
+}
+
+// ChainOut outputs the events as JSON when piped, otherwise a timeline of state transitions
+func (events *PaymentEvents) ChainOut() error {
+	output := resourceOutput{
+		value:          events,
+		ids:            []string{events.PaymentID},
+		header:         table.Row{"Time", "State", "Finished", "Since previous", "Since start"},
+		terminalFormat: OutputTable,
+		pipedFormat:    OutputJSON,
+	}
+	for index, event := range events.Events {
+		sincePrevious, sinceStart := "", ""
+		if index > 0 {
+			sincePrevious = "+" + event.Updated.Sub(events.Events[index-1].Updated).String()
+			sinceStart = "+" + event.Updated.Sub(events.Events[0].Updated).String()
+		}
+		output.items = append(output.items, &events.Events[index])
+		output.rows = append(output.rows, table.Row{
+			event.Updated.Format("2006-01-02 15:04:05.000"), event.State.Status, event.State.Finished, sincePrevious, sinceStart,
+		})
+	}
+	return output.write()
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/logrusorgru/aurora"
+	"github.com/tidwall/pretty"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	OutputJSON     = "json"
+	OutputYAML     = "yaml"
+	OutputTable    = "table"
+	OutputID       = "id"
+	OutputCSV      = "csv"
+	OutputTemplate = "template"
+)
+
+var OutputFormats = []string{OutputJSON, OutputYAML, OutputTable, OutputID, OutputCSV, OutputTemplate}
+
+// OutputOptions control how ChainOut writes API resources. Without a format each resource picks a
+// default for the context it is called in, usually a table or JSON on a terminal and IDs when piped
+type OutputOptions struct {
+	Format   string
+	Template string
+}
+
+// Output is configured once from the global --output and --template flags
+var Output OutputOptions
+
+// Validate checks the format is supported and that a template is given when it is needed
+func (options OutputOptions) Validate() error {
+	if options.Format != "" && !contains(OutputFormats, options.Format) {
+		return fmt.Errorf("Unsupported output format %s, expected one of %s", options.Format, strings.Join(OutputFormats, ", "))
+	}
+	if options.Format == OutputTemplate && options.Template == "" {
+		return errors.New("A template (--template) is required with --output template")
+	}
+	if options.Template != "" {
+		_, err := template.New("output").Parse(options.Template)
+		if err != nil {
+			return fmt.Errorf("Invalid output template: %w", err)
+		}
+	}
+	return nil
+}
+
+// resourceOutput describes each of the ways a resource, or a list of resources, can be written
+type resourceOutput struct {
+	// value is written as JSON or YAML
+	value interface{}
+
+	// items are each written on their own line with a template
+	items []interface{}
+
+	ids []string
+
+	// summary is shown above JSON on a terminal when the format has been left to the called context
+	summary string
+
+	header table.Row
+	rows   []table.Row
+	footer table.Row
+
+	terminalFormat string
+	pipedFormat    string
+}
+
+func (output resourceOutput) write() error {
+	format, err := output.format()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case OutputJSON:
+		jsonBytes, err := json.Marshal(output.value)
+		if err != nil {
+			return err
+		}
+		if isTerminal, _ := stdoutIsTerminal(); isTerminal {
+			if output.summary != "" && Output.IsDefault() {
+				fmt.Printf("> %s\n", aurora.Bold(output.summary))
+			}
+			fmt.Printf("%s", pretty.Color(pretty.Pretty(jsonBytes), nil))
+		} else {
+			fmt.Printf("%s", pretty.Pretty(jsonBytes))
+		}
+	case OutputYAML:
+		yamlBytes, err := toYAML(output.value)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s", yamlBytes)
+	case OutputTable:
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		t.AppendHeader(output.header)
+		t.AppendRows(output.rows)
+		if output.footer != nil {
+			t.AppendFooter(output.footer)
+		}
+		t.Render()
+	case OutputID:
+		for _, id := range output.ids {
+			fmt.Println(id)
+		}
+	case OutputCSV:
+		writer := csv.NewWriter(os.Stdout)
+		writer.Write(csvRecord(output.header))
+		for _, row := range output.rows {
+			writer.Write(csvRecord(row))
+		}
+		writer.Flush()
+		return writer.Error()
+	case OutputTemplate:
+		outputTemplate, err := template.New("output").Parse(Output.Template)
+		if err != nil {
+			return fmt.Errorf("Invalid output template: %w", err)
+		}
+		for _, item := range output.items {
+			err := outputTemplate.Execute(os.Stdout, item)
+			if err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+	}
+	return nil
+}
+
+// format prefers the user's choice, then the resource default for the called context
+func (output resourceOutput) format() (string, error) {
+	if Output.Format != "" {
+		return Output.Format, nil
+	}
+	if Output.Template != "" {
+		return OutputTemplate, nil
+	}
+	isTerminal, err := stdoutIsTerminal()
+	if err != nil {
+		return "", err
+	}
+	if isTerminal {
+		return output.terminalFormat, nil
+	}
+	return output.pipedFormat, nil
+}
+
+// IsDefault reports whether the user has left the output format to the called context
+func (options OutputOptions) IsDefault() bool {
+	return options.Format == "" && options.Template == ""
+}
+
+func stdoutIsTerminal() (bool, error) {
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return false, err
+	}
+	return (fi.Mode() & os.ModeCharDevice) != 0, nil
+}
+
+// toYAML goes through JSON so that field names and omitted fields match the API
+func toYAML(value interface{}) ([]byte, error) {
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var ordered interface{}
+	if strings.HasPrefix(strings.TrimSpace(string(jsonBytes)), "[") {
+		var list []yaml.MapSlice
+		err = yaml.Unmarshal(jsonBytes, &list)
+		ordered = list
+	} else {
+		var object yaml.MapSlice
+		err = yaml.Unmarshal(jsonBytes, &object)
+		ordered = object
+	}
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(ordered)
+}
+
+func csvRecord(row table.Row) []string {
+	record := make([]string, len(row))
+	for index, cell := range row {
+		if amount, ok := cell.(Amount); ok {
+			record[index] = strconv.Itoa(int(amount))
+		} else {
+			record[index] = fmt.Sprint(cell)
+		}
+	}
+	return record
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Output", func() {
+	Context("Validating output options", func() {
+		Specify("Supported formats should be accepted", func() {
+			Expect(OutputOptions{Format: OutputYAML}.Validate()).Should(Succeed())
+			Expect(OutputOptions{Template: "{{.ID}}"}.Validate()).Should(Succeed())
+		})
+
+		Specify("Unsupported formats and missing or invalid templates should be rejected", func() {
+			Expect(OutputOptions{Format: "xml"}.Validate()).ShouldNot(Succeed())
+			Expect(OutputOptions{Format: OutputTemplate}.Validate()).ShouldNot(Succeed())
+			Expect(OutputOptions{Template: "{{.ID"}.Validate()).ShouldNot(Succeed())
+		})
+	})
+
+	Context("Converting resources to YAML", func() {
+		Specify("Field names and order should match the API JSON", func() {
+			payment := Payment{ID: "abc", Amount: 1000, State: PaymentState{Status: "success", Finished: true}}
+			yamlBytes, err := toYAML(&payment)
+			Expect(err).Should(BeNil())
+			Expect(string(yamlBytes)).Should(HavePrefix("payment_id: abc\namount: 1000\n"))
+			Expect(string(yamlBytes)).Should(ContainSubstring("state:\n  status: success\n  finished: true\n"))
+		})
+
+		Specify("Lists should be converted to YAML sequences", func() {
+			yamlBytes, err := toYAML(Refunds{{ID: "one"}, {ID: "two"}})
+			Expect(err).Should(BeNil())
+			Expect(string(yamlBytes)).Should(HavePrefix("- refund_id: one\n"))
+			Expect(string(yamlBytes)).Should(ContainSubstring("- refund_id: two\n"))
+		})
+	})
+})
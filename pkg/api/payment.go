@@ -1,113 +1,386 @@
 package api
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"os"
 
 	"github.com/alphagov/pay-cli/pkg/config"
-	"github.com/tidwall/pretty"
+	"github.com/jedib0t/go-pretty/table"
+	"github.com/logrusorgru/aurora"
 )
 
 type Link struct {
-	Href   string `json:"href"`
-	Method string `json:"method"`
+	Href   string            `json:"href"`
+	Method string            `json:"method"`
+	Type   string            `json:"type,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
 }
 
 type PaymentLinks struct {
-	NextURL    Link `json:"next_url"`
-	ToolboxURL Link `json:"toolbox_url"`
+	Self        Link `json:"self"`
+	NextURL     Link `json:"next_url"`
+	NextURLPost Link `json:"next_url_post"`
+	AuthURLPost Link `json:"auth_url_post"`
+	Events      Link `json:"events"`
+	Refunds     Link `json:"refunds"`
+	Cancel      Link `json:"cancel"`
+	Capture     Link `json:"capture"`
+	ToolboxURL  Link `json:"toolbox_url"`
+}
+
+type PaymentState struct {
+	Status   string `json:"status"`
+	Finished bool   `json:"finished"`
+	Message  string `json:"message,omitempty"`
+	Code     string `json:"code,omitempty"`
+	CanRetry *bool  `json:"can_retry,omitempty"`
+}
+
+type Address struct {
+	Line1    string `json:"line1"`
+	Line2    string `json:"line2"`
+	Postcode string `json:"postcode"`
+	City     string `json:"city"`
+	Country  string `json:"country"`
+}
+
+type CardDetails struct {
+	LastDigitsCardNumber  string  `json:"last_digits_card_number"`
+	FirstDigitsCardNumber string  `json:"first_digits_card_number"`
+	CardholderName        string  `json:"cardholder_name"`
+	ExpiryDate            string  `json:"expiry_date"`
+	BillingAddress        Address `json:"billing_address"`
+	CardBrand             string  `json:"card_brand"`
+	CardType              string  `json:"card_type"`
+	WalletType            string  `json:"wallet_type,omitempty"`
+}
+
+type RefundSummary struct {
+	Status          string `json:"status"`
+	AmountAvailable int    `json:"amount_available"`
+	AmountSubmitted int    `json:"amount_submitted"`
+}
+
+type SettlementSummary struct {
+	CaptureSubmitTime string `json:"capture_submit_time,omitempty"`
+	CapturedDate      string `json:"captured_date,omitempty"`
+	SettledDate       string `json:"settled_date,omitempty"`
+}
+
+type ThreeDSecure struct {
+	Required bool `json:"required"`
+}
+
+type AuthorisationSummary struct {
+	ThreeDSecure ThreeDSecure `json:"three_d_secure"`
 }
 
 type Payment struct {
-	ID              string       `json:"payment_id"`
-	Amount          int          `json:"amount"`
-	Reference       string       `json:"reference"`
-	Description     string       `json:"description"`
-	PaymentProvider string       `json:"payment_provider"`
-	Links           PaymentLinks `json:"_links"`
+	ID                     string                 `json:"payment_id"`
+	Amount                 int                    `json:"amount"`
+	TotalAmount            *int                   `json:"total_amount,omitempty"`
+	CorporateCardSurcharge *int                   `json:"corporate_card_surcharge,omitempty"`
+	Fee                    *int                   `json:"fee,omitempty"`
+	NetAmount              *int                   `json:"net_amount,omitempty"`
+	Reference              string                 `json:"reference"`
+	Description            string                 `json:"description"`
+	Language               string                 `json:"language"`
+	Email                  string                 `json:"email,omitempty"`
+	Metadata               map[string]interface{} `json:"metadata,omitempty"`
+	State                  PaymentState           `json:"state"`
+	CreatedDate            string                 `json:"created_date"`
+	ReturnURL              string                 `json:"return_url"`
+	CardDetails            CardDetails            `json:"card_details"`
+	RefundSummary          RefundSummary          `json:"refund_summary"`
+	SettlementSummary      SettlementSummary      `json:"settlement_summary"`
+	AuthorisationSummary   AuthorisationSummary   `json:"authorisation_summary"`
+	DelayedCapture         bool                   `json:"delayed_capture"`
+	Moto                   bool                   `json:"moto"`
+	PaymentProvider        string                 `json:"payment_provider"`
+	ProviderID             string                 `json:"provider_id,omitempty"`
+	AuthorisationMode      string                 `json:"authorisation_mode,omitempty"`
+	AgreementID            string                 `json:"agreement_id,omitempty"`
+	Links                  PaymentLinks           `json:"_links"`
 }
 
+// Payments is a list of payments returned from a search
+type Payments []Payment
+
 type RefundLinks struct {
 	Self       Link `json:"self"`
 	Payment    Link `json:"payment"`
 	ToolboxURL Link `json:"toolbox_url"`
 }
 
+type RefundSettlementSummary struct {
+	SettledDate string `json:"settled_date,omitempty"`
+}
+
 type Refund struct {
-	ID     string      `json:"refund_id"`
-	Amount int         `json:"amount"`
-	Status string      `json:"status"`
-	Links  RefundLinks `json:"_links"`
+	ID                string                  `json:"refund_id"`
+	PaymentID         string                  `json:"payment_id,omitempty"`
+	CreatedDate       string                  `json:"created_date"`
+	Amount            int                     `json:"amount"`
+	Status            string                  `json:"status"`
+	SettlementSummary RefundSettlementSummary `json:"settlement_summary"`
+	Links             RefundLinks             `json:"_links"`
+}
+
+// Refunds is a list of refunds for a payment
+type Refunds []Refund
+
+type AgreementLinks struct {
+	ToolboxURL Link `json:"toolbox_url"`
+}
+
+type PaymentInstrument struct {
+	Type        string      `json:"type"`
+	CardDetails CardDetails `json:"card_details"`
+	CreatedDate string      `json:"created_date"`
+}
+
+type Agreement struct {
+	ID                string             `json:"agreement_id"`
+	Reference         string             `json:"reference"`
+	Description       string             `json:"description"`
+	Status            string             `json:"status"`
+	CreatedDate       string             `json:"created_date"`
+	UserIdentifier    string             `json:"user_identifier,omitempty"`
+	PaymentInstrument *PaymentInstrument `json:"payment_instrument,omitempty"`
+	Links             AgreementLinks     `json:"_links"`
+}
+
+// Agreements is a list of agreements returned from a search
+type Agreements []Agreement
+
+type DisputeLinks struct {
+	Payment    Link `json:"payment"`
+	ToolboxURL Link `json:"toolbox_url"`
+}
+
+type DisputeSettlementSummary struct {
+	SettledDate string `json:"settled_date,omitempty"`
+}
+
+type Dispute struct {
+	ID                string                   `json:"dispute_id"`
+	PaymentID         string                   `json:"payment_id"`
+	CreatedDate       string                   `json:"created_date"`
+	EvidenceDueDate   string                   `json:"evidence_due_date,omitempty"`
+	Amount            int                      `json:"amount"`
+	Fee               *int                     `json:"fee,omitempty"`
+	NetAmount         *int                     `json:"net_amount,omitempty"`
+	Status            string                   `json:"status"`
+	Reason            string                   `json:"reason"`
+	SettlementSummary DisputeSettlementSummary `json:"settlement_summary"`
+	Links             DisputeLinks             `json:"_links"`
+}
+
+// Disputes is a list of disputes returned from a search
+type Disputes []Dispute
+
+var paymentHeader = table.Row{"Payment ID", "Created", "State", "Amount", "Reference", "Description", "Provider"}
+
+func (payment *Payment) tableRow() table.Row {
+	return table.Row{
+		payment.ID, payment.CreatedDate, payment.State.Status, Amount(payment.Amount), payment.Reference, payment.Description, payment.PaymentProvider,
+	}
 }
 
 // chainOut outputs the result of the response to stdout depending on the called context
 func (payment *Payment) ChainOut(shouldOutputNextURL bool) error {
-	fi, err := os.Stdout.Stat()
+	id := payment.ID
+	if shouldOutputNextURL {
+		id = payment.Links.NextURL.Href
+	}
+	return resourceOutput{
+		value:          payment,
+		items:          []interface{}{payment},
+		ids:            []string{id},
+		summary:        fmt.Sprintf("Payment %s for %s is %s", payment.ID, Amount(payment.Amount), payment.State.Status),
+		header:         paymentHeader,
+		rows:           []table.Row{payment.tableRow()},
+		terminalFormat: OutputJSON,
+		pipedFormat:    OutputID,
+	}.write()
+}
+
+// StateChainOut outputs the payment ID when piped, otherwise a summary of the current payment state
+func (payment *Payment) StateChainOut() error {
+	isTerminal, err := stdoutIsTerminal()
 	if err != nil {
 		return err
 	}
 
-	// context is sending data to a pipe
-	if (fi.Mode() & os.ModeCharDevice) == 0 {
-		var output string
-		if shouldOutputNextURL {
-			output = payment.Links.NextURL.Href
-		} else {
-			output = payment.ID
-		}
-		fmt.Print(output)
-	} else {
-		// context is directly back to terminal
-		jsonBytes, err := json.Marshal(payment)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("%s", pretty.Color(pretty.Pretty(jsonBytes), nil))
+	if isTerminal && Output.IsDefault() {
+		fmt.Printf("> Payment %s is now %s\n", aurora.Bold(aurora.Cyan(payment.ID)), aurora.Bold(payment.State.Status))
+		return nil
+	}
+	return payment.ChainOut(false)
+}
+
+// ChainOut outputs one payment ID per line when piped, otherwise a table of payments
+func (payments Payments) ChainOut() error {
+	if payments == nil {
+		payments = Payments{}
+	}
+	output := resourceOutput{
+		value:          payments,
+		header:         paymentHeader,
+		footer:         table.Row{"", "", "", "", "", "Total", len(payments)},
+		terminalFormat: OutputTable,
+		pipedFormat:    OutputID,
+	}
+	for index := range payments {
+		output.items = append(output.items, &payments[index])
+		output.ids = append(output.ids, payments[index].ID)
+		output.rows = append(output.rows, payments[index].tableRow())
+	}
+	return output.write()
+}
+
+var refundHeader = table.Row{"Refund ID", "Payment ID", "Created", "Amount", "Status", "Settled"}
+
+func (refund *Refund) tableRow() table.Row {
+	return table.Row{
+		refund.ID, refund.PaymentID, refund.CreatedDate, Amount(refund.Amount), refund.Status, refund.SettlementSummary.SettledDate,
 	}
-	return nil
 }
 
 // chainOut outputs the result of the response to stdout depending on the called context
 func (refund *Refund) ChainOut() error {
-	fi, err := os.Stdout.Stat()
-	if err != nil {
-		return err
+	return resourceOutput{
+		value:          refund,
+		items:          []interface{}{refund},
+		ids:            []string{refund.ID},
+		summary:        fmt.Sprintf("Refund %s for %s is %s", refund.ID, Amount(refund.Amount), refund.Status),
+		header:         refundHeader,
+		rows:           []table.Row{refund.tableRow()},
+		terminalFormat: OutputJSON,
+		pipedFormat:    OutputID,
+	}.write()
+}
+
+// ChainOut outputs one refund ID per line when piped, otherwise a table of refunds with the total in pounds
+func (refunds Refunds) ChainOut() error {
+	if refunds == nil {
+		refunds = Refunds{}
+	}
+	total := 0
+	output := resourceOutput{
+		value:          refunds,
+		header:         refundHeader,
+		terminalFormat: OutputTable,
+		pipedFormat:    OutputID,
+	}
+	for index := range refunds {
+		total += refunds[index].Amount
+		output.items = append(output.items, &refunds[index])
+		output.ids = append(output.ids, refunds[index].ID)
+		output.rows = append(output.rows, refunds[index].tableRow())
 	}
+	output.footer = table.Row{fmt.Sprintf("%d refunds", len(refunds)), "", "Total", FormatAmount(total), "", ""}
+	return output.write()
+}
+
+var agreementHeader = table.Row{"Agreement ID", "Created", "Status", "Reference", "Description", "User identifier"}
 
-	// context is sending data to a pipe
-	if (fi.Mode() & os.ModeCharDevice) == 0 {
-		fmt.Print(refund.ID)
-	} else {
-		// context is directly back to terminal
-		jsonBytes, err := json.Marshal(refund)
-		if err != nil {
-			return err
-		}
-		fmt.Printf("%s", pretty.Color(pretty.Pretty(jsonBytes), nil))
+func (agreement *Agreement) tableRow() table.Row {
+	return table.Row{
+		agreement.ID, agreement.CreatedDate, agreement.Status, agreement.Reference, agreement.Description, agreement.UserIdentifier,
 	}
-	return nil
 }
 
-func (payment *Payment) parse(res *http.Response) {
-	json.NewDecoder(res.Body).Decode(payment)
+// chainOut outputs the result of the response to stdout depending on the called context
+func (agreement *Agreement) ChainOut() error {
+	return resourceOutput{
+		value:          agreement,
+		items:          []interface{}{agreement},
+		ids:            []string{agreement.ID},
+		header:         agreementHeader,
+		rows:           []table.Row{agreement.tableRow()},
+		terminalFormat: OutputJSON,
+		pipedFormat:    OutputID,
+	}.write()
 }
 
-func (refund *Refund) parse(res *http.Response) {
-	json.NewDecoder(res.Body).Decode(refund)
+// ChainOut outputs one agreement ID per line when piped, otherwise a table of agreements
+func (agreements Agreements) ChainOut() error {
+	if agreements == nil {
+		agreements = Agreements{}
+	}
+	output := resourceOutput{
+		value:          agreements,
+		header:         agreementHeader,
+		footer:         table.Row{"", "", "", "", "Total", len(agreements)},
+		terminalFormat: OutputTable,
+		pipedFormat:    OutputID,
+	}
+	for index := range agreements {
+		output.items = append(output.items, &agreements[index])
+		output.ids = append(output.ids, agreements[index].ID)
+		output.rows = append(output.rows, agreements[index].tableRow())
+	}
+	return output.write()
+}
+
+var disputeHeader = table.Row{"Dispute ID", "Payment ID", "Created", "Evidence due", "Status", "Reason", "Amount", "Toolbox"}
+
+func (dispute *Dispute) tableRow() table.Row {
+	return table.Row{
+		dispute.ID, dispute.PaymentID, dispute.CreatedDate, dispute.EvidenceDueDate, dispute.Status, dispute.Reason, Amount(dispute.Amount), dispute.Links.ToolboxURL.Href,
+	}
+}
+
+// ChainOut outputs one dispute ID per line when piped, otherwise a table of disputes
+func (disputes Disputes) ChainOut() error {
+	if disputes == nil {
+		disputes = Disputes{}
+	}
+	output := resourceOutput{
+		value:          disputes,
+		header:         disputeHeader,
+		footer:         table.Row{"", "", "", "", "", "", "Total", len(disputes)},
+		terminalFormat: OutputTable,
+		pipedFormat:    OutputID,
+	}
+	for index := range disputes {
+		output.items = append(output.items, &disputes[index])
+		output.ids = append(output.ids, disputes[index].ID)
+		output.rows = append(output.rows, disputes[index].tableRow())
+	}
+	return output.write()
+}
+
+// IsTerminal reports whether the refund has stopped progressing, submitted refunds are still with the provider
+func (refund *Refund) IsTerminal() bool {
+	return refund.Status == "success" || refund.Status == "error"
 }
 
 func (payment *Payment) furnishToolboxURL(environment config.Environment) {
 	payment.Links.ToolboxURL = Link{
-		Href:   fmt.Sprintf("https://toolbox.%s/transactions/%s", environment.BaseURL, payment.ID),
+		Href:   fmt.Sprintf("%s/transactions/%s", environment.ToolboxURL(), payment.ID),
 		Method: "GET",
 	}
 }
 
 func (refund *Refund) furnishToolboxURL(environment config.Environment) {
 	refund.Links.ToolboxURL = Link{
-		Href:   fmt.Sprintf("https://toolbox.%s/transactions/%s", environment.BaseURL, refund.ID),
+		Href:   fmt.Sprintf("%s/transactions/%s", environment.ToolboxURL(), refund.ID),
+		Method: "GET",
+	}
+}
+
+func (agreement *Agreement) furnishToolboxURL(environment config.Environment) {
+	agreement.Links.ToolboxURL = Link{
+		Href:   fmt.Sprintf("%s/agreements/%s", environment.ToolboxURL(), agreement.ID),
+		Method: "GET",
+	}
+}
+
+// furnishToolboxURL links to the disputed payment, Toolbox shows its disputes on the payment's transaction
+func (dispute *Dispute) furnishToolboxURL(environment config.Environment) {
+	dispute.Links.ToolboxURL = Link{
+		Href:   fmt.Sprintf("%s/transactions/%s", environment.ToolboxURL(), dispute.PaymentID),
 		Method: "GET",
 	}
 }
@@ -1,53 +1,203 @@
 package api
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
-	"github.com/alphagov/pay-cli/pkg/config"
+	"github.com/gorilla/schema"
 )
 
+// RefundPaymentRequest optionally carries the amount the caller believes is available to refund, the API
+// rejects the refund if it has changed so two concurrent refunds can't both be made
 type RefundPaymentRequest struct {
-	Amount int `json:"amount"`
+	Amount                int  `json:"amount"`
+	RefundAmountAvailable *int `json:"refund_amount_available,omitempty"`
 }
 
-func RefundPayment(id string, amount int, environment config.Environment) error {
+// RefundCheck describes a refund to check against the payment before it is sent
+type RefundCheck struct {
+	Amount int
+
+	// Full refunds whatever amount is still available, Amount is ignored
+	Full bool
+
+	// ExpectedAvailable guards against other refunds made since the caller last looked at the payment
+	ExpectedAvailable *int
+}
+
+type SearchRefundsRequest struct {
+	FromDate        string `schema:"from_date,omitempty"`
+	ToDate          string `schema:"to_date,omitempty"`
+	FromSettledDate string `schema:"from_settled_date,omitempty"`
+	ToSettledDate   string `schema:"to_settled_date,omitempty"`
+}
+
+type RefundSearchResults struct {
+	Total   int         `json:"total"`
+	Count   int         `json:"count"`
+	Page    int         `json:"page"`
+	Results Refunds     `json:"results"`
+	Links   SearchLinks `json:"_links"`
+}
+
+type RefundsForPayment struct {
+	PaymentID string `json:"payment_id"`
+	Embedded  struct {
+		Refunds Refunds `json:"refunds"`
+	} `json:"_embedded"`
+}
+
+// PrepareRefund fetches the payment and checks the refund can be made, the returned request is pinned
+// to the amount available so that the API rejects it if another refund gets there first
+func (client *Client) PrepareRefund(id string, check RefundCheck) (RefundPaymentRequest, error) {
+	var request RefundPaymentRequest
+
+	payment, err := client.GetPayment(id)
+	if err != nil {
+		return request, err
+	}
+	summary := payment.RefundSummary
+
+	if check.ExpectedAvailable != nil && *check.ExpectedAvailable != summary.AmountAvailable {
+		return request, fmt.Errorf("Expected %s to be available to refund on payment %s but %s is available, another refund may have been made", FormatAmount(*check.ExpectedAvailable), id, FormatAmount(summary.AmountAvailable))
+	}
+
+	switch summary.Status {
+	case "available":
+	case "full":
+		return request, fmt.Errorf("Payment %s has already been fully refunded", id)
+	case "pending":
+		return request, fmt.Errorf("Payment %s cannot be refunded yet, it is %s and has not been captured", id, payment.State.Status)
+	case "unavailable":
+		return request, fmt.Errorf("Payment %s cannot be refunded, it is %s", id, payment.State.Status)
+	default:
+		return request, fmt.Errorf("Payment %s cannot be refunded, refund status is %s", id, summary.Status)
+	}
+
+	amount := check.Amount
+	if check.Full {
+		amount = summary.AmountAvailable
+	}
+	if amount <= 0 {
+		return request, errors.New("Refund amount must be more than £0.00")
+	}
+	if amount > summary.AmountAvailable {
+		return request, fmt.Errorf("Cannot refund %s on payment %s, only %s of %s is available to refund", FormatAmount(amount), id, FormatAmount(summary.AmountAvailable), FormatAmount(payment.Amount))
+	}
+
+	available := summary.AmountAvailable
+	request.Amount = amount
+	request.RefundAmountAvailable = &available
+	return request, nil
+}
+
+func (client *Client) RefundPayment(id string, request RefundPaymentRequest) (Refund, error) {
+	var refund Refund
+
 	if strings.TrimSpace(id) == "" {
-		return errors.New("Invalid payment ID provided, unable to refund payment")
+		return refund, errors.New("Invalid payment ID provided, unable to refund payment")
 	}
 
-	target := fmt.Sprintf("v1/payments/%s/refunds", id)
-	url := fmt.Sprintf("https://publicapi.%s/%s", environment.BaseURL, target)
-	request := RefundPaymentRequest{
-		Amount: amount,
+	err := client.Post(fmt.Sprintf("v1/payments/%s/refunds", id), request, &refund)
+	if err != nil {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusPreconditionFailed {
+			return refund, fmt.Errorf("The amount available to refund on payment %s has changed, another refund may have been made: %w", id, err)
+		}
+		return refund, err
 	}
-	payload := strings.NewReader(request.format())
-	req, _ := http.NewRequest("POST", url, payload)
+	refund.furnishToolboxURL(client.Environment)
+	return refund, nil
+}
 
-	req.Header.Add("content-type", "application/json")
-	req.Header.Add("authorization", fmt.Sprintf("Bearer %s", environment.APIKey))
+func (client *Client) GetRefund(paymentID string, refundID string) (Refund, error) {
+	var refund Refund
+
+	if strings.TrimSpace(paymentID) == "" || strings.TrimSpace(refundID) == "" {
+		return refund, errors.New("Both a payment ID and a refund ID are required to get a refund")
+	}
 
-	res, err := http.DefaultClient.Do(req)
+	err := client.Get(fmt.Sprintf("v1/payments/%s/refunds/%s", paymentID, refundID), &refund)
 	if err != nil {
-		return err
+		return refund, err
 	}
-	defer res.Body.Close()
+	refund.furnishToolboxURL(client.Environment)
+	return refund, nil
+}
+
+func (client *Client) GetRefundsForPayment(id string) (Refunds, error) {
+	var refunds RefundsForPayment
 
-	if res.StatusCode != 200 && res.StatusCode != 202 {
-		return fmt.Errorf("Refund payment request returned non-success code %d", res.StatusCode)
+	if strings.TrimSpace(id) == "" {
+		return nil, errors.New("Invalid payment ID provided, unable to get refunds")
 	}
 
-	var refund Refund
-	refund.parse(res)
-	refund.furnishToolboxURL(environment)
-	refund.ChainOut()
-	return nil
+	err := client.Get(fmt.Sprintf("v1/payments/%s/refunds", id), &refunds)
+	if err != nil {
+		return nil, err
+	}
+	for index := range refunds.Embedded.Refunds {
+		refunds.Embedded.Refunds[index].furnishToolboxURL(client.Environment)
+	}
+	return refunds.Embedded.Refunds, nil
+}
+
+// SearchRefunds returns all refunds across the account matching the request, following the next page
+// links until every page has been fetched
+func (client *Client) SearchRefunds(request SearchRefundsRequest) (Refunds, error) {
+	var refunds Refunds
+
+	query, err := request.format()
+	if err != nil {
+		return refunds, err
+	}
+
+	pageURL := "v1/refunds?" + query.Encode()
+	for pageURL != "" {
+		var results RefundSearchResults
+		err := client.Get(pageURL, &results)
+		if err != nil {
+			return refunds, err
+		}
+		for _, refund := range results.Results {
+			refund.furnishToolboxURL(client.Environment)
+			refunds = append(refunds, refund)
+		}
+		pageURL = results.Links.NextPage.Href
+	}
+	return refunds, nil
+}
+
+var refundPollInterval = time.Second
+
+// WaitForRefund polls a refund until it reaches a terminal status or the timeout expires
+func (client *Client) WaitForRefund(paymentID string, refundID string, timeout time.Duration) (Refund, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		refund, err := client.GetRefund(paymentID, refundID)
+		if err != nil {
+			return refund, err
+		}
+		if refund.IsTerminal() {
+			if refund.Status == "error" {
+				return refund, fmt.Errorf("Refund %s finished with status error", refundID)
+			}
+			return refund, nil
+		}
+		if time.Now().After(deadline) {
+			return refund, fmt.Errorf("Timed out after %s waiting for refund %s, last seen status was %s", timeout, refundID, refund.Status)
+		}
+		time.Sleep(refundPollInterval)
+	}
 }
 
-func (refundRequest *RefundPaymentRequest) format() string {
-	result, _ := json.Marshal(refundRequest)
-	return string(result)
+func (searchRequest *SearchRefundsRequest) format() (url.Values, error) {
+	encoder := schema.NewEncoder()
+	query := url.Values{}
+	err := encoder.Encode(searchRequest, query)
+	return query, err
 }
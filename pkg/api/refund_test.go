@@ -0,0 +1,186 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Refunds", func() {
+	var server *httptest.Server
+	var client *Client
+	var refundStatuses []string
+	var refundPolls int
+	var refundSearches []url.Values
+
+	// refund summaries for each payment ID, amounts are in pence
+	payments := map[string]string{
+		"available":   `{"status": "available", "amount_available": 1500, "amount_submitted": 500}`,
+		"full":        `{"status": "full", "amount_available": 0, "amount_submitted": 2000}`,
+		"pending":     `{"status": "pending", "amount_available": 0, "amount_submitted": 0}`,
+		"unavailable": `{"status": "unavailable", "amount_available": 0, "amount_submitted": 0}`,
+		"unknown":     `{"status": "error", "amount_available": 0, "amount_submitted": 0}`,
+	}
+
+	BeforeEach(func() {
+		refundPolls = 0
+		refundSearches = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+			switch {
+			case r.URL.Path == "/v1/refunds":
+				refundSearches = append(refundSearches, r.URL.Query())
+				if r.URL.Query().Get("page") == "2" {
+					fmt.Fprint(w, `{"page": 2, "results": [{"refund_id": "r3", "payment_id": "p2"}], "_links": {}}`)
+					return
+				}
+				fmt.Fprintf(w, `{"page": 1, "results": [{"refund_id": "r1", "payment_id": "p1"}, {"refund_id": "r2", "payment_id": "p1"}],
+					"_links": {"next_page": {"href": "http://%s/v1/refunds?page=2&from_date=%s"}}}`, r.Host, r.URL.Query().Get("from_date"))
+			case len(path) == 3 && r.Method == "GET":
+				fmt.Fprintf(w, `{"payment_id": "%s", "amount": 2000, "state": {"status": "success", "finished": true}, "refund_summary": %s}`, path[2], payments[path[2]])
+			case len(path) == 4 && r.Method == "GET":
+				fmt.Fprintf(w, `{"payment_id": "%s", "_embedded": {"refunds": [{"refund_id": "r1", "amount": 500, "status": "success"}, {"refund_id": "r2", "amount": 100, "status": "submitted"}]}}`, path[2])
+			case len(path) == 4 && path[2] == "conflict" && r.Method == "POST":
+				w.WriteHeader(http.StatusPreconditionFailed)
+				fmt.Fprint(w, `{"code": "P0604", "description": "Refund amount available mismatch"}`)
+			case len(path) == 5 && r.Method == "GET":
+				status := refundStatuses[refundPolls]
+				if refundPolls < len(refundStatuses)-1 {
+					refundPolls++
+				}
+				fmt.Fprintf(w, `{"refund_id": "%s", "payment_id": "%s", "status": "%s"}`, path[4], path[2], status)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		client = NewClient(config.Environment{APIKey: "api_test_key", BaseURL: server.URL})
+		refundPollInterval = time.Millisecond
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Getting refunds", func() {
+		Specify("A refund should link to Toolbox", func() {
+			refundStatuses = []string{"success"}
+			refund, err := client.GetRefund("p1", "r1")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(refund.ID).Should(Equal("r1"))
+			Expect(refund.PaymentID).Should(Equal("p1"))
+			Expect(refund.Links.ToolboxURL.Href).Should(HaveSuffix("/transactions/r1"))
+		})
+
+		Specify("Both IDs should be required to get a refund", func() {
+			_, err := client.GetRefund("p1", " ")
+			Expect(err).Should(MatchError("Both a payment ID and a refund ID are required to get a refund"))
+		})
+
+		Specify("The refunds for a payment should be read from the embedded list and link to Toolbox", func() {
+			refunds, err := client.GetRefundsForPayment("p1")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(refunds).Should(HaveLen(2))
+			Expect(refunds[0].Amount).Should(Equal(500))
+			Expect(refunds[1].Status).Should(Equal("submitted"))
+			for _, refund := range refunds {
+				Expect(refund.Links.ToolboxURL.Href).Should(HaveSuffix("/transactions/" + refund.ID))
+			}
+		})
+	})
+
+	Context("Preparing a refund", func() {
+		Specify("A full refund should refund the amount available and pin it", func() {
+			request, err := client.PrepareRefund("available", RefundCheck{Full: true, Amount: 100})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(request.Amount).Should(Equal(1500))
+			Expect(*request.RefundAmountAvailable).Should(Equal(1500))
+		})
+
+		Specify("A partial refund should refund the amount given", func() {
+			request, err := client.PrepareRefund("available", RefundCheck{Amount: 500})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(request.Amount).Should(Equal(500))
+			Expect(*request.RefundAmountAvailable).Should(Equal(1500))
+		})
+
+		Specify("Refunding more than is available should fail", func() {
+			_, err := client.PrepareRefund("available", RefundCheck{Amount: 1501})
+			Expect(err).Should(MatchError("Cannot refund £15.01 on payment available, only £15.00 of £20.00 is available to refund"))
+		})
+
+		Specify("Refunding nothing should fail", func() {
+			_, err := client.PrepareRefund("available", RefundCheck{})
+			Expect(err).Should(MatchError("Refund amount must be more than £0.00"))
+		})
+
+		Specify("A different amount available than expected should fail", func() {
+			expected := 2000
+			_, err := client.PrepareRefund("available", RefundCheck{Full: true, ExpectedAvailable: &expected})
+			Expect(err).Should(MatchError("Expected £20.00 to be available to refund on payment available but £15.00 is available, another refund may have been made"))
+		})
+
+		Specify("Payments that can't be refunded should fail with the reason", func() {
+			for id, message := range map[string]string{
+				"full":        "Payment full has already been fully refunded",
+				"pending":     "Payment pending cannot be refunded yet, it is success and has not been captured",
+				"unavailable": "Payment unavailable cannot be refunded, it is success",
+				"unknown":     "Payment unknown cannot be refunded, refund status is error",
+			} {
+				_, err := client.PrepareRefund(id, RefundCheck{Full: true})
+				Expect(err).Should(MatchError(message), id)
+			}
+		})
+	})
+
+	Specify("A changed amount available should be explained when the refund is rejected", func() {
+		available := 1500
+		_, err := client.RefundPayment("conflict", RefundPaymentRequest{Amount: 100, RefundAmountAvailable: &available})
+		Expect(err).Should(MatchError(HavePrefix("The amount available to refund on payment conflict has changed, another refund may have been made")))
+	})
+
+	Specify("Searching refunds should follow every page", func() {
+		refunds, err := client.SearchRefunds(SearchRefundsRequest{FromDate: "2020-01-01T00:00:00Z"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(refunds).Should(HaveLen(3))
+		Expect(refunds[2].ID).Should(Equal("r3"))
+		Expect(refunds[0].Links.ToolboxURL.Href).ShouldNot(BeEmpty())
+	})
+
+	Specify("Searching refunds should send the date filters on every page", func() {
+		_, err := client.SearchRefunds(SearchRefundsRequest{FromDate: "2020-01-01T00:00:00Z", ToDate: "2020-02-01T00:00:00Z"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(refundSearches).Should(HaveLen(2))
+		Expect(refundSearches[0].Get("from_date")).Should(Equal("2020-01-01T00:00:00Z"))
+		Expect(refundSearches[0].Get("to_date")).Should(Equal("2020-02-01T00:00:00Z"))
+		Expect(refundSearches[1].Get("page")).Should(Equal("2"))
+		Expect(refundSearches[1].Get("from_date")).Should(Equal("2020-01-01T00:00:00Z"))
+	})
+
+	Context("Waiting for a refund", func() {
+		Specify("Polling should stop once the refund succeeds", func() {
+			refundStatuses = []string{"submitted", "submitted", "success"}
+			refund, err := client.WaitForRefund("p1", "r1", time.Second)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(refund.Status).Should(Equal("success"))
+		})
+
+		Specify("A refund that errors should fail", func() {
+			refundStatuses = []string{"submitted", "error"}
+			_, err := client.WaitForRefund("p1", "r1", time.Second)
+			Expect(err).Should(MatchError("Refund r1 finished with status error"))
+		})
+
+		Specify("Timing out should fail with the last seen status", func() {
+			refundStatuses = []string{"submitted"}
+			_, err := client.WaitForRefund("p1", "r1", 10*time.Millisecond)
+			Expect(err).Should(MatchError(HavePrefix("Timed out after 10ms waiting for refund r1, last seen status was submitted")))
+		})
+	})
+})
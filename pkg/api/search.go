@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/schema"
+)
+
+type SearchPaymentsRequest struct {
+	Reference             string `schema:"reference,omitempty"`
+	Email                 string `schema:"email,omitempty"`
+	State                 string `schema:"state,omitempty"`
+	CardBrand             string `schema:"card_brand,omitempty"`
+	LastDigitsCardNumber  string `schema:"last_digits_card_number,omitempty"`
+	FirstDigitsCardNumber string `schema:"first_digits_card_number,omitempty"`
+	CardholderName        string `schema:"cardholder_name,omitempty"`
+	FromDate              string `schema:"from_date,omitempty"`
+	ToDate                string `schema:"to_date,omitempty"`
+}
+
+type SearchLinks struct {
+	Self      Link `json:"self"`
+	FirstPage Link `json:"first_page"`
+	LastPage  Link `json:"last_page"`
+	PrevPage  Link `json:"prev_page"`
+	NextPage  Link `json:"next_page"`
+}
+
+type PaymentSearchResults struct {
+	Total   int         `json:"total"`
+	Count   int         `json:"count"`
+	Page    int         `json:"page"`
+	Results []Payment   `json:"results"`
+	Links   SearchLinks `json:"_links"`
+}
+
+// SearchPayments returns all payments matching the request, following the next page links until
+// every page has been fetched or the limit has been reached (a limit of 0 fetches everything)
+func (client *Client) SearchPayments(request SearchPaymentsRequest, limit int) (Payments, error) {
+	var payments Payments
+
+	query, err := request.format()
+	if err != nil {
+		return payments, err
+	}
+
+	pageURL := "v1/payments?" + query.Encode()
+	for pageURL != "" {
+		var results PaymentSearchResults
+		err := client.Get(pageURL, &results)
+		if err != nil {
+			return payments, err
+		}
+		for _, payment := range results.Results {
+			payment.furnishToolboxURL(client.Environment)
+			payments = append(payments, payment)
+			if limit > 0 && len(payments) >= limit {
+				return payments, nil
+			}
+		}
+		pageURL = results.Links.NextPage.Href
+	}
+	return payments, nil
+}
+
+// FormatSearchDate accepts either a plain date (2006-01-02) or a full RFC 3339 timestamp and
+// returns the ISO 8601 format expected by the search endpoints
+func FormatSearchDate(input string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", nil
+	}
+	if date, err := time.Parse("2006-01-02", input); err == nil {
+		return date.UTC().Format(time.RFC3339), nil
+	}
+	if date, err := time.Parse(time.RFC3339, input); err == nil {
+		return date.UTC().Format(time.RFC3339), nil
+	}
+	return "", errors.New("Invalid date " + input + ", expected YYYY-MM-DD or an RFC 3339 timestamp")
+}
+
+func (searchRequest *SearchPaymentsRequest) format() (url.Values, error) {
+	encoder := schema.NewEncoder()
+	query := url.Values{}
+	err := encoder.Encode(searchRequest, query)
+	return query, err
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/alphagov/pay-cli/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Searching payments", func() {
+	var server *httptest.Server
+	var client *Client
+	var searches []url.Values
+
+	BeforeEach(func() {
+		searches = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			searches = append(searches, r.URL.Query())
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprint(w, `{"page": 2, "results": [{"payment_id": "p3"}], "_links": {}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"page": 1, "results": [{"payment_id": "p1"}, {"payment_id": "p2"}],
+				"_links": {"next_page": {"href": "http://%s/v1/payments?page=2&state=%s"}}}`, r.Host, r.URL.Query().Get("state"))
+		}))
+		client = NewClient(config.Environment{APIKey: "api_test_key", BaseURL: server.URL})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Specify("Searching should follow every page", func() {
+		payments, err := client.SearchPayments(SearchPaymentsRequest{State: "success", Reference: "ref"}, 0)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payments).Should(HaveLen(3))
+		Expect(payments[2].ID).Should(Equal("p3"))
+		Expect(payments[0].Links.ToolboxURL.Href).ShouldNot(BeEmpty())
+		Expect(searches).Should(HaveLen(2))
+		Expect(searches[0].Get("state")).Should(Equal("success"))
+		Expect(searches[0].Get("reference")).Should(Equal("ref"))
+		Expect(searches[0]).ShouldNot(HaveKey("email"))
+	})
+
+	Specify("Searching should stop once the limit is reached", func() {
+		payments, err := client.SearchPayments(SearchPaymentsRequest{}, 2)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payments).Should(HaveLen(2))
+		Expect(searches).Should(HaveLen(1))
+	})
+
+	Specify("Dates should be converted to UTC timestamps", func() {
+		for input, expected := range map[string]string{
+			"":                          "",
+			"2020-03-01":                "2020-03-01T00:00:00Z",
+			" 2020-03-01 ":              "2020-03-01T00:00:00Z",
+			"2020-03-01T12:30:00+01:00": "2020-03-01T11:30:00Z",
+		} {
+			Expect(FormatSearchDate(input)).Should(Equal(expected), input)
+		}
+
+		_, err := FormatSearchDate("01/03/2020")
+		Expect(err).Should(MatchError("Invalid date 01/03/2020, expected YYYY-MM-DD or an RFC 3339 timestamp"))
+	})
+})
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// StateCaptured is not a payment state, a payment waited on for captured has succeeded and has a
+// captured date in its settlement summary
+const StateCaptured = "captured"
+
+var PaymentStates = []string{"created", "started", "submitted", "capturable", "success", "failed", "cancelled", "error", StateCaptured}
+
+var waitBaseDelay = 500 * time.Millisecond
+
+var waitMaxDelay = 5 * time.Second
+
+// WaitForPayment polls a payment with backoff until it reaches state, the last seen payment is returned
+// with an error if it finishes in a different state or the timeout expires
+func (client *Client) WaitForPayment(id string, state string, timeout time.Duration) (Payment, error) {
+	if !contains(PaymentStates, state) {
+		return Payment{}, fmt.Errorf("Unknown payment state %s, expected one of %s", state, strings.Join(PaymentStates, ", "))
+	}
+	return client.pollPayment(id, "reach "+state, timeout, func(payment Payment, err error) (bool, error) {
+		if err != nil {
+			return true, err
+		}
+		if payment.hasReached(state) {
+			return true, nil
+		}
+		if payment.State.Finished && !(state == StateCaptured && payment.State.Status == "success") {
+			return true, fmt.Errorf("Payment %s finished in state %s without reaching %s%s", id, payment.State.Status, state, payment.State.describeFailure())
+		}
+		return false, nil
+	})
+}
+
+// WaitForOutcome polls a payment with backoff until the paying user's part is over, when it has finished
+// or is capturable
+func (client *Client) WaitForOutcome(id string, timeout time.Duration) (Payment, error) {
+	return client.pollPayment(id, "finish", timeout, func(payment Payment, err error) (bool, error) {
+		return err != nil || payment.State.Finished || payment.State.Status == "capturable", err
+	})
+}
+
+// WaitForPaymentToExist polls a payment with backoff until the API stops returning not found, payments
+// that have just been created can take a moment to be available, whatever state they are in
+func (client *Client) WaitForPaymentToExist(id string, timeout time.Duration) (Payment, error) {
+	return client.pollPayment(id, "be found", timeout, func(payment Payment, err error) (bool, error) {
+		var apiErr *Error
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+// pollPayment gets a payment until done says to stop, backing off between requests, done is given any
+// error getting the payment so it can decide whether to keep polling
+func (client *Client) pollPayment(id string, goal string, timeout time.Duration, done func(Payment, error) (bool, error)) (Payment, error) {
+	deadline := time.Now().Add(timeout)
+	delay := waitBaseDelay
+	for {
+		payment, err := client.GetPayment(id)
+		if stop, err := done(payment, err); stop {
+			return payment, err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if err != nil {
+				return payment, fmt.Errorf("Timed out after %s waiting for payment %s to %s: %w", timeout, id, goal, err)
+			}
+			return payment, fmt.Errorf("Timed out after %s waiting for payment %s to %s, last seen state was %s", timeout, id, goal, payment.State.Status)
+		}
+		if delay > remaining {
+			delay = remaining
+		}
+		time.Sleep(delay)
+		delay *= 2
+		if delay > waitMaxDelay {
+			delay = waitMaxDelay
+		}
+	}
+}
+
+func (payment *Payment) hasReached(state string) bool {
+	if state == StateCaptured {
+		return payment.State.Status == "success" && payment.SettlementSummary.CapturedDate != ""
+	}
+	return payment.State.Status == state
+}
+
+func (state *PaymentState) describeFailure() string {
+	if state.Code == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (%s: %s)", state.Code, state.Message)
+}
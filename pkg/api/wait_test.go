@@ -0,0 +1,83 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Waiting for a payment", func() {
+	var server *httptest.Server
+	var client *Client
+	var states []string
+	var polls int
+
+	BeforeEach(func() {
+		polls = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state := states[polls]
+			if polls < len(states)-1 {
+				polls++
+			}
+			if state == "missing" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"code": "P0200", "description": "Not found"}`)
+				return
+			}
+			finished := state == "success" || state == "failed"
+			fmt.Fprintf(w, `{"payment_id": "abc", "state": {"status": "%s", "finished": %t}}`, state, finished)
+		}))
+		client = NewClient(config.Environment{APIKey: "api_test_key", BaseURL: server.URL})
+		waitBaseDelay = time.Millisecond
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Specify("Polling should stop once the payment reaches the state", func() {
+		states = []string{"created", "submitted", "success"}
+		payment, err := client.WaitForPayment("abc", "success", time.Second)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payment.State.Status).Should(Equal("success"))
+	})
+
+	Specify("A different terminal state should fail with the state that was reached", func() {
+		states = []string{"submitted", "failed"}
+		_, err := client.WaitForPayment("abc", "success", time.Second)
+		Expect(err).Should(MatchError("Payment abc finished in state failed without reaching success"))
+	})
+
+	Specify("Timing out should fail with the last seen state", func() {
+		states = []string{"started"}
+		_, err := client.WaitForPayment("abc", "success", 20*time.Millisecond)
+		Expect(err).Should(MatchError(HavePrefix("Timed out after 20ms waiting for payment abc to reach success, last seen state was started")))
+	})
+
+	Specify("Waiting for the outcome should stop at capturable", func() {
+		states = []string{"submitted", "capturable", "success"}
+		payment, err := client.WaitForOutcome("abc", time.Second)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payment.State.Status).Should(Equal("capturable"))
+	})
+
+	Context("Waiting for a payment to be found", func() {
+		Specify("Polling should stop once the payment is found in any state", func() {
+			states = []string{"missing", "missing", "failed"}
+			payment, err := client.WaitForPaymentToExist("abc", time.Second)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(payment.State.Status).Should(Equal("failed"))
+		})
+
+		Specify("Timing out should fail with the not found error", func() {
+			states = []string{"missing"}
+			_, err := client.WaitForPaymentToExist("abc", 20*time.Millisecond)
+			Expect(err).Should(MatchError(HavePrefix("Timed out after 20ms waiting for payment abc to be found: GOV.UK Pay API returned 404")))
+		})
+	})
+})
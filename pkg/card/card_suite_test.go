@@ -0,0 +1,13 @@
+package card_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestCard(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Card Test Suite")
+}
@@ -0,0 +1,123 @@
+package card
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alphagov/pay-cli/pkg/api"
+	"github.com/alphagov/pay-cli/pkg/config"
+)
+
+// CardDetails are entered on the card details page, presets in the configuration file use the
+// mapstructure names, e.g.
+//
+//	[staging.cards.refused]
+//	number = "4444333322221111"
+//	cardholder_name = "REFUSED"
+type CardDetails struct {
+	Number          string `mapstructure:"number"`
+	ExpiryMonth     string `mapstructure:"expiry_month"`
+	ExpiryYear      string `mapstructure:"expiry_year"`
+	CVC             string `mapstructure:"cvc"`
+	CardholderName  string `mapstructure:"cardholder_name"`
+	AddressLine1    string `mapstructure:"address_line1"`
+	AddressCity     string `mapstructure:"address_city"`
+	AddressPostcode string `mapstructure:"address_postcode"`
+	AddressCountry  string `mapstructure:"address_country"`
+	Email           string `mapstructure:"email"`
+
+	// ThreeDS is how a 3-D Secure challenge is answered, if the card is challenged
+	ThreeDS ThreeDSResult `mapstructure:"three_ds"`
+}
+
+// DefaultCardDetails fill in anything not set by a preset or flag
+var DefaultCardDetails = CardDetails{
+	Number:          "4242424242424242",
+	ExpiryMonth:     "01",
+	ExpiryYear:      "2030",
+	CVC:             "123",
+	CardholderName:  "Pay CLI User",
+	AddressLine1:    "10 Whitechapel High St",
+	AddressCity:     "London",
+	AddressPostcode: "E18QS",
+	AddressCountry:  "GB",
+	Email:           "pay@cli.gov.uk",
+	ThreeDS:         ThreeDSPass,
+}
+
+// Presets are test card numbers recognised by the sandbox and by Stripe test mode, presets prefixed stripe-
+// are only recognised by Stripe. Other providers need their own numbers configured as presets
+var Presets = map[string]CardDetails{
+	"visa":       {Number: "4444333322221111"},
+	"mastercard": {Number: "5105105105105100"},
+	"amex":       {Number: "371449635398431", CVC: "1234"},
+	"maestro":    {Number: "6759649826438453"},
+	"declined":   {Number: "4000000000000002"},
+	"expired":    {Number: "4000000000000069"},
+	"cvc-fail":   {Number: "4000000000000127"},
+	"error":      {Number: "4000000000000119"},
+	"stripe-3ds": {Number: "4000000000003220"},
+}
+
+// ResolveCardDetails builds the card details to enter, flags take precedence over the named preset which
+// takes precedence over the defaults. Presets configured for the environment replace built in presets
+// with the same name
+func ResolveCardDetails(preset string, flags CardDetails, environment config.Environment) (CardDetails, error) {
+	details := flags
+	if preset != "" {
+		presetDetails, err := lookupPreset(preset, environment)
+		if err != nil {
+			return details, err
+		}
+		err = api.Replace(presetDetails, &details)
+		if err != nil {
+			return details, err
+		}
+	}
+	err := api.Replace(DefaultCardDetails, &details)
+	if err != nil {
+		return details, err
+	}
+	details.Number = strings.Replace(details.Number, " ", "", -1)
+	return details, nil
+}
+
+// ParseExpiry accepts an expiry date as MM/YY or MM/YYYY
+func ParseExpiry(expiry string) (string, string, error) {
+	parts := strings.Split(strings.TrimSpace(expiry), "/")
+	if len(parts) != 2 || len(parts[0]) != 2 || (len(parts[1]) != 2 && len(parts[1]) != 4) {
+		return "", "", fmt.Errorf("Invalid expiry date %s, expected MM/YY or MM/YYYY", expiry)
+	}
+	year := parts[1]
+	if len(year) == 2 {
+		year = "20" + year
+	}
+	return parts[0], year, nil
+}
+
+func lookupPreset(name string, environment config.Environment) (CardDetails, error) {
+	var configured map[string]CardDetails
+	err := environment.UnmarshalConfig("cards", &configured)
+	if err != nil {
+		return CardDetails{}, fmt.Errorf("Invalid card presets in configuration: %w", err)
+	}
+	if details, found := configured[name]; found {
+		return details, nil
+	}
+	if details, found := Presets[name]; found {
+		return details, nil
+	}
+
+	var names []string
+	for preset := range Presets {
+		names = append(names, preset)
+	}
+	for preset := range configured {
+		if _, builtIn := Presets[preset]; !builtIn {
+			names = append(names, preset)
+		}
+	}
+	sort.Strings(names)
+	return CardDetails{}, fmt.Errorf("Unknown card preset %s, expected one of %s", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,51 @@
+package card_test
+
+import (
+	"github.com/alphagov/pay-cli/pkg/card"
+	"github.com/alphagov/pay-cli/pkg/config"
+	"github.com/spf13/viper"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Card details", func() {
+	environment := config.Environment{Name: "cardtest"}
+
+	AfterEach(func() {
+		viper.Set("cardtest.cards", nil)
+	})
+
+	Specify("Flags should take precedence over the preset, which takes precedence over the defaults", func() {
+		details, err := card.ResolveCardDetails("amex", card.CardDetails{CardholderName: "A Flag"}, environment)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(details.Number).Should(Equal("371449635398431"))
+		Expect(details.CVC).Should(Equal("1234"))
+		Expect(details.CardholderName).Should(Equal("A Flag"))
+		Expect(details.AddressCity).Should(Equal(card.DefaultCardDetails.AddressCity))
+	})
+
+	Specify("Presets configured for the environment should be available by name", func() {
+		viper.Set("cardtest.cards", map[string]interface{}{
+			"refused": map[string]interface{}{"number": "4444 3333 2222 1111", "cardholder_name": "REFUSED"},
+		})
+		details, err := card.ResolveCardDetails("refused", card.CardDetails{}, environment)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(details.Number).Should(Equal("4444333322221111"))
+		Expect(details.CardholderName).Should(Equal("REFUSED"))
+	})
+
+	Specify("An unknown preset should list the presets that can be used", func() {
+		_, err := card.ResolveCardDetails("unknown", card.CardDetails{}, environment)
+		Expect(err).Should(MatchError("Unknown card preset unknown, expected one of amex, cvc-fail, declined, error, expired, maestro, mastercard, stripe-3ds, visa"))
+	})
+
+	Specify("Expiry dates should accept two or four digit years", func() {
+		month, year, err := card.ParseExpiry("04/31")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect([]string{month, year}).Should(Equal([]string{"04", "2031"}))
+
+		_, _, err = card.ParseExpiry("4/2031")
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,197 @@
+package card_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/api"
+	"github.com/alphagov/pay-cli/pkg/card"
+	"github.com/alphagov/pay-cli/pkg/config"
+	"github.com/alphagov/pay-cli/pkg/mock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Making card payments against the mock server", func() {
+	var server *httptest.Server
+	var environment config.Environment
+	var client *api.Client
+
+	// reportedState replaces the state the API reports for payments, to disagree with the frontend
+	var reportedState string
+
+	BeforeEach(func() {
+		reportedState = ""
+		mockServer := mock.NewServer()
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if reportedState == "" || r.Method != "GET" || strings.Count(r.URL.Path, "/") != 3 || !strings.HasPrefix(r.URL.Path, "/v1/payments/") {
+				mockServer.ServeHTTP(w, r)
+				return
+			}
+			recorder := httptest.NewRecorder()
+			mockServer.ServeHTTP(recorder, r)
+			var payment api.Payment
+			json.NewDecoder(recorder.Body).Decode(&payment)
+			payment.State = api.PaymentState{Status: reportedState, Finished: true}
+			json.NewEncoder(w).Encode(payment)
+		}))
+		environment = config.Environment{APIKey: "api_test_mock", BaseURL: server.URL}
+		client = api.NewClient(environment)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Specify("A payment made from its next url should succeed and be refundable", func() {
+		created, err := client.CreatePayment(api.CreatePaymentRequest{Amount: 1500})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		result, err := card.MakeCardPayment(created.Links.NextURL.Href, card.DefaultCardDetails, client, time.Second)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Outcome).Should(Equal(card.OutcomeAuthorised))
+		Expect(result.State).Should(Equal("success"))
+		Expect(result.CardBrand).Should(Equal("visa"))
+		Expect(result.LastDigits).Should(Equal("4242"))
+
+		payment, err := client.GetPayment(created.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payment.State.Status).Should(Equal("success"))
+		Expect(payment.CardDetails.LastDigitsCardNumber).Should(Equal("4242"))
+		Expect(payment.CardDetails.CardBrand).Should(Equal("visa"))
+
+		request, err := client.PrepareRefund(created.ID, api.RefundCheck{Full: true})
+		Expect(err).ShouldNot(HaveOccurred())
+		refund, err := client.RefundPayment(created.ID, request)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(refund.Amount).Should(Equal(1500))
+		Expect(refund.Status).Should(Equal("success"))
+	})
+
+	Specify("A delayed capture payment made from its payment ID should wait to be captured", func() {
+		created, err := client.CreatePayment(api.CreatePaymentRequest{DelayedCapture: true})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		result, err := card.MakeCardPayment(created.ID, card.DefaultCardDetails, client, time.Second)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Expect(card.OutcomeAuthorised)).Should(Succeed())
+
+		payment, err := client.GetPayment(created.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payment.State.Status).Should(Equal("capturable"))
+
+		payment, err = client.CapturePayment(created.ID)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(payment.State.Status).Should(Equal("success"))
+	})
+
+	Specify("A payment the API doesn't agree was authorised should fail", func() {
+		created, err := client.CreatePayment(api.CreatePaymentRequest{})
+		Expect(err).ShouldNot(HaveOccurred())
+		reportedState = "failed"
+
+		result, err := card.MakeCardPayment(created.ID, card.DefaultCardDetails, client, time.Second)
+		Expect(err).Should(MatchError("Card payment " + created.ID + " was authorised on the frontend but the API reports it as failed"))
+		Expect(result.Outcome).Should(Equal(card.OutcomeAuthorised))
+		Expect(result.State).Should(Equal("failed"))
+	})
+
+	Context("Classifying journeys that are not authorised", func() {
+		pay := func(preset string, flags card.CardDetails) card.CardPaymentResult {
+			created, err := client.CreatePayment(api.CreatePaymentRequest{})
+			Expect(err).ShouldNot(HaveOccurred())
+			details, err := card.ResolveCardDetails(preset, flags, environment)
+			Expect(err).ShouldNot(HaveOccurred())
+			result, err := card.MakeCardPayment(created.ID, details, client, time.Second)
+			Expect(err).ShouldNot(HaveOccurred())
+			return result
+		}
+
+		Specify("A declined card should be declined", func() {
+			result := pay("declined", card.CardDetails{})
+			Expect(result.Outcome).Should(Equal(card.OutcomeDeclined))
+			Expect(result.Message).Should(Equal("Your payment has been declined"))
+			Expect(result.Expect(card.OutcomeAuthorised)).Should(MatchError("Expected card payment " + result.PaymentID + " to be authorised but it was declined (Your payment has been declined)"))
+		})
+
+		Specify("A provider error should be an error", func() {
+			Expect(pay("error", card.CardDetails{}).Outcome).Should(Equal(card.OutcomeError))
+		})
+
+		Specify("Invalid card details should fail validation", func() {
+			result := pay("", card.CardDetails{CVC: "1"})
+			Expect(result.Outcome).Should(Equal(card.OutcomeValidationFailed))
+			Expect(result.Message).Should(Equal("Enter a valid card security code"))
+		})
+
+		Specify("A payment cancelled before the journey should be cancelled", func() {
+			created, err := client.CreatePayment(api.CreatePaymentRequest{})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = client.CancelPayment(created.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			result, err := card.MakeCardPayment(created.Links.NextURL.Href, card.DefaultCardDetails, client, time.Second)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.Outcome).Should(Equal(card.OutcomeCancelled))
+		})
+	})
+
+	Context("Answering 3-D Secure challenges", func() {
+		pay := func(result card.ThreeDSResult) (card.CardPaymentResult, api.Payment) {
+			created, err := client.CreatePayment(api.CreatePaymentRequest{})
+			Expect(err).ShouldNot(HaveOccurred())
+			details, err := card.ResolveCardDetails("stripe-3ds", card.CardDetails{ThreeDS: result}, environment)
+			Expect(err).ShouldNot(HaveOccurred())
+			journey, err := card.MakeCardPayment(created.ID, details, client, time.Second)
+			Expect(err).ShouldNot(HaveOccurred())
+			payment, err := client.GetPayment(created.ID)
+			Expect(err).ShouldNot(HaveOccurred())
+			return journey, payment
+		}
+
+		Specify("A passed challenge should be authorised", func() {
+			journey, payment := pay(card.ThreeDSPass)
+			Expect(journey.Outcome).Should(Equal(card.OutcomeAuthorised))
+			Expect(payment.State.Status).Should(Equal("success"))
+		})
+
+		Specify("A failed challenge should be declined", func() {
+			journey, payment := pay(card.ThreeDSFail)
+			Expect(journey.Outcome).Should(Equal(card.OutcomeDeclined))
+			Expect(payment.State.Status).Should(Equal("failed"))
+		})
+
+		Specify("An abandoned challenge should leave the payment started", func() {
+			journey, payment := pay(card.ThreeDSAbandon)
+			Expect(journey.Outcome).Should(Equal(card.OutcomeAbandoned))
+			Expect(payment.State.Status).Should(Equal("started"))
+		})
+	})
+
+	Specify("A load test should time every step of each journey", func() {
+		result, err := card.RunLoadTest(client, card.LoadTestOptions{
+			Count:         6,
+			Concurrency:   3,
+			Details:       card.DefaultCardDetails,
+			Expected:      card.OutcomeAuthorised,
+			VerifyTimeout: time.Second,
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(result.Succeeded).Should(Equal(6))
+		Expect(result.Journeys).Should(HaveLen(6))
+		for index, journey := range result.Journeys {
+			Expect(journey.Index).Should(Equal(index + 1))
+			Expect(journey.Outcome).Should(Equal(card.OutcomeAuthorised))
+			Expect(journey.Timings).Should(HaveLen(7))
+		}
+
+		var csv strings.Builder
+		Expect(result.WriteCSV(&csv)).Should(Succeed())
+		lines := strings.Split(strings.TrimSpace(csv.String()), "\n")
+		Expect(lines).Should(HaveLen(7))
+		Expect(lines[0]).Should(HavePrefix("index,payment_id,outcome,error,failed_step,auth_attempts,create_payment_ms"))
+	})
+})
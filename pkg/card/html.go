@@ -1,6 +1,8 @@
 package card
 
 import (
+	"strings"
+
 	"golang.org/x/net/html"
 )
 
@@ -42,3 +44,50 @@ func traverse(n *html.Node, id string) *html.Node {
 func GetElementById(n *html.Node, id string) *html.Node {
 	return traverse(n, id)
 }
+
+// FindElement returns the first element, depth first, that matches
+func FindElement(n *html.Node, matches func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && matches(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		result := FindElement(c, matches)
+		if result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// GetText returns the text content of a node and its children with whitespace collapsed
+func GetText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var builder strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			builder.WriteString(n.Data)
+			builder.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+func hasClass(n *html.Node, class string) bool {
+	classes, found := GetAttribute(n, "class")
+	if !found {
+		return false
+	}
+	for _, candidate := range strings.Fields(classes) {
+		if candidate == class {
+			return true
+		}
+	}
+	return false
+}
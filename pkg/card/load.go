@@ -0,0 +1,259 @@
+package card
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/api"
+	"github.com/alphagov/pay-cli/pkg/common"
+	"github.com/jedib0t/go-pretty/table"
+)
+
+// StepCreatePayment is timed by load tests before the card journey starts
+const StepCreatePayment = "create payment"
+
+var loadTestSteps = []string{StepCreatePayment, StepCardDetailsPage, StepCardDetails, StepThreeDS, StepConfirmPage, StepConfirm, StepVerify}
+
+// csvColumns name the timing of each step in CSV exports
+var csvColumns = map[string]string{
+	StepCreatePayment:   "create_payment_ms",
+	StepCardDetailsPage: "card_details_page_ms",
+	StepCardDetails:     "card_details_ms",
+	StepThreeDS:         "three_ds_ms",
+	StepConfirmPage:     "confirm_page_ms",
+	StepConfirm:         "confirm_ms",
+	StepVerify:          "verify_ms",
+}
+
+type LoadTestOptions struct {
+	Count       int
+	Concurrency int
+
+	// Request creates the payment for each journey, Details are entered for every payment
+	Request       api.CreatePaymentRequest
+	Details       CardDetails
+	Expected      Outcome
+	VerifyTimeout time.Duration
+}
+
+// Journey is one card journey made by a load test, Error is empty if it ended with the expected outcome
+type Journey struct {
+	Index        int
+	PaymentID    string
+	Outcome      Outcome
+	Error        string
+	FailedStep   string
+	AuthAttempts int
+	Timings      []StepTiming
+	Elapsed      time.Duration
+}
+
+type LoadTestResult struct {
+	Requested int
+	Succeeded int
+	Journeys  []Journey
+	Elapsed   time.Duration
+}
+
+// RunLoadTest makes options.Count card journeys using a pool of workers, each journey creates a payment
+// with client and pays for it with its own frontend session
+func RunLoadTest(client *api.Client, options LoadTestOptions) (LoadTestResult, error) {
+	result := LoadTestResult{Requested: options.Count}
+	if options.Count < 1 {
+		return result, errors.New("Count must be at least 1 to make card journeys")
+	}
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
+
+	indexes := make(chan int)
+	var mutex sync.Mutex
+	var workers sync.WaitGroup
+	start := time.Now()
+
+	for worker := 0; worker < options.Concurrency; worker++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for index := range indexes {
+				journey := runJourney(index, client, options)
+				mutex.Lock()
+				result.Journeys = append(result.Journeys, journey)
+				if journey.Error == "" {
+					result.Succeeded++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for index := 1; index <= options.Count; index++ {
+		indexes <- index
+	}
+	close(indexes)
+	workers.Wait()
+
+	result.Elapsed = time.Since(start)
+	sort.Slice(result.Journeys, func(i, j int) bool {
+		return result.Journeys[i].Index < result.Journeys[j].Index
+	})
+	return result, nil
+}
+
+func runJourney(index int, client *api.Client, options LoadTestOptions) (journey Journey) {
+	journey.Index = index
+	start := time.Now()
+	defer func() {
+		journey.Elapsed = time.Since(start)
+	}()
+
+	payment, err := client.CreatePayment(options.Request)
+	journey.Timings = append(journey.Timings, StepTiming{Step: StepCreatePayment, Duration: time.Since(start)})
+	if err != nil {
+		journey.FailedStep = StepCreatePayment
+		journey.Error = err.Error()
+		return journey
+	}
+	journey.PaymentID = payment.ID
+
+	process := CardPaymentProcess{
+		NextURL:     payment.Links.NextURL.Href,
+		Environment: client.Environment,
+		Client:      client,
+		CardDetails: options.Details,
+		Quiet:       true,
+	}
+	err = process.run(options.VerifyTimeout)
+	journey.Outcome = process.Outcome
+	journey.FailedStep = process.FailedStep
+	journey.AuthAttempts = process.AuthAttempts
+	journey.Timings = append(journey.Timings, process.Timings...)
+	if err == nil {
+		err = process.result().Expect(options.Expected)
+	}
+	if err != nil {
+		// the payment ID is left out so that the same failure on different payments is grouped together
+		journey.Error = strings.Replace(err.Error(), payment.ID+" ", "", -1)
+	}
+	return journey
+}
+
+// Summarise writes throughput, latency percentiles for each step, outcomes and failures
+func (result *LoadTestResult) Summarise(out io.Writer) {
+	t := table.NewWriter()
+	t.SetOutputMirror(out)
+	t.SetTitle("Card journeys")
+	t.AppendRows([]table.Row{
+		{"Requested", result.Requested},
+		{"Succeeded", result.Succeeded},
+		{"Failed", result.Requested - result.Succeeded},
+		{"Elapsed", result.Elapsed.Round(time.Millisecond)},
+		{"Throughput", fmt.Sprintf("%.2f/s", float64(result.Succeeded)/result.Elapsed.Seconds())},
+	})
+	t.Render()
+
+	timings := make(map[string][]time.Duration)
+	var journeys []time.Duration
+	for _, journey := range result.Journeys {
+		for _, timing := range journey.Timings {
+			timings[timing.Step] = append(timings[timing.Step], timing.Duration)
+		}
+		journeys = append(journeys, journey.Elapsed)
+	}
+	steps := table.NewWriter()
+	steps.SetOutputMirror(out)
+	steps.AppendHeader(table.Row{"Step", "Count", "p50", "p95", "p99", "Max"})
+	for _, step := range loadTestSteps {
+		if len(timings[step]) > 0 {
+			steps.AppendRow(latencyRow(step, timings[step]))
+		}
+	}
+	steps.AppendRow(latencyRow("whole journey", journeys))
+	steps.Render()
+
+	outcomes := make(map[string]int)
+	failures := make(map[string]int)
+	for _, journey := range result.Journeys {
+		if journey.Outcome != "" {
+			outcomes[string(journey.Outcome)]++
+		}
+		if journey.Error != "" && journey.FailedStep != "" {
+			failures[fmt.Sprintf("%s: %s", journey.FailedStep, journey.Error)]++
+		} else if journey.Error != "" {
+			failures[journey.Error]++
+		}
+	}
+	renderCounts(out, "Outcome", outcomes)
+	renderCounts(out, "Error", failures)
+}
+
+// WriteCSV writes one row for each journey with the time taken by each step in milliseconds
+func (result *LoadTestResult) WriteCSV(out io.Writer) error {
+	writer := csv.NewWriter(out)
+	header := []string{"index", "payment_id", "outcome", "error", "failed_step", "auth_attempts"}
+	for _, step := range loadTestSteps {
+		header = append(header, csvColumns[step])
+	}
+	header = append(header, "journey_ms")
+	writer.Write(header)
+
+	for _, journey := range result.Journeys {
+		durations := make(map[string]time.Duration)
+		for _, timing := range journey.Timings {
+			durations[timing.Step] = timing.Duration
+		}
+		row := []string{strconv.Itoa(journey.Index), journey.PaymentID, string(journey.Outcome), journey.Error, journey.FailedStep, strconv.Itoa(journey.AuthAttempts)}
+		for _, step := range loadTestSteps {
+			if duration, found := durations[step]; found {
+				row = append(row, milliseconds(duration))
+			} else {
+				row = append(row, "")
+			}
+		}
+		row = append(row, milliseconds(journey.Elapsed))
+		writer.Write(row)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func latencyRow(name string, durations []time.Duration) table.Row {
+	return table.Row{
+		name,
+		len(durations),
+		common.Percentile(durations, 50).Round(time.Millisecond),
+		common.Percentile(durations, 95).Round(time.Millisecond),
+		common.Percentile(durations, 99).Round(time.Millisecond),
+		common.Percentile(durations, 100).Round(time.Millisecond),
+	}
+}
+
+func renderCounts(out io.Writer, heading string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	t := table.NewWriter()
+	t.SetOutputMirror(out)
+	t.AppendHeader(table.Row{heading, "Count"})
+	for _, key := range keys {
+		t.AppendRow(table.Row{key, counts[key]})
+	}
+	t.Render()
+}
+
+func milliseconds(duration time.Duration) string {
+	return strconv.FormatFloat(float64(duration)/float64(time.Millisecond), 'f', 1, 64)
+}
@@ -0,0 +1,97 @@
+package card
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Outcome is how a card journey ended from the paying user's point of view
+type Outcome string
+
+const (
+	OutcomeAuthorised       Outcome = "authorised"
+	OutcomeDeclined         Outcome = "declined"
+	OutcomeError            Outcome = "error"
+	OutcomeCancelled        Outcome = "cancelled"
+	OutcomeValidationFailed Outcome = "validation-failed"
+
+	// OutcomeAbandoned journeys were left at the 3-D Secure challenge
+	OutcomeAbandoned Outcome = "abandoned"
+)
+
+var Outcomes = []Outcome{OutcomeAuthorised, OutcomeDeclined, OutcomeError, OutcomeCancelled, OutcomeValidationFailed, OutcomeAbandoned}
+
+// CardPaymentResult describes the end of a card journey, Message is the heading or error shown on the
+// page the journey ended on. State and the card are as the API reported them once the journey ended
+type CardPaymentResult struct {
+	PaymentID  string
+	Outcome    Outcome
+	Message    string
+	State      string
+	CardBrand  string
+	LastDigits string
+}
+
+// outcomeStates are the payment states the API can report after each outcome, journeys that are
+// abandoned or fail validation leave the payment started so have nothing to check
+var outcomeStates = map[Outcome][]string{
+	OutcomeAuthorised: {"success", "capturable"},
+	OutcomeDeclined:   {"failed"},
+	OutcomeError:      {"error"},
+	// the paying user cancelling fails the payment, the service cancelling it cancels it
+	OutcomeCancelled: {"cancelled", "failed"},
+}
+
+// ParseOutcome checks an outcome given on the command line
+func ParseOutcome(value string) (Outcome, error) {
+	for _, outcome := range Outcomes {
+		if string(outcome) == value {
+			return outcome, nil
+		}
+	}
+	names := make([]string, len(Outcomes))
+	for index, outcome := range Outcomes {
+		names[index] = string(outcome)
+	}
+	return "", fmt.Errorf("Unknown outcome %s, expected one of %s", value, strings.Join(names, ", "))
+}
+
+// Expect returns an error unless the journey ended with the expected outcome
+func (result CardPaymentResult) Expect(expected Outcome) error {
+	if result.Outcome == expected {
+		return nil
+	}
+	message := ""
+	if result.Message != "" {
+		message = fmt.Sprintf(" (%s)", result.Message)
+	}
+	if result.PaymentID == "" {
+		return fmt.Errorf("Expected card payment to be %s but it was %s%s", expected, result.Outcome, message)
+	}
+	return fmt.Errorf("Expected card payment %s to be %s but it was %s%s", result.PaymentID, expected, result.Outcome, message)
+}
+
+// ClassifyPage recognises the pages that end a card journey before it is authorised, the card details and
+// confirm pages that continue the journey are not an outcome
+func ClassifyPage(document *html.Node) (Outcome, string, bool) {
+	errorSummary := FindElement(document, func(n *html.Node) bool {
+		return hasClass(n, "govuk-error-summary") || checkID(n, "error-summary")
+	})
+	if errorSummary != nil && GetElementById(document, "card-details") != nil {
+		return OutcomeValidationFailed, GetText(errorSummary), true
+	}
+
+	heading := GetText(FindElement(document, func(n *html.Node) bool { return n.Data == "h1" }))
+	text := strings.ToLower(heading)
+	switch {
+	case strings.Contains(text, "declined"):
+		return OutcomeDeclined, heading, true
+	case strings.Contains(text, "cancelled"):
+		return OutcomeCancelled, heading, true
+	case strings.Contains(text, "problem") || strings.Contains(text, "error") || strings.Contains(text, "technical"):
+		return OutcomeError, heading, true
+	}
+	return "", "", false
+}
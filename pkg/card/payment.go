@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/alphagov/pay-cli/pkg/api"
+	"github.com/alphagov/pay-cli/pkg/common"
 	"github.com/alphagov/pay-cli/pkg/config"
 	"github.com/briandowns/spinner"
 	"github.com/gorilla/schema"
@@ -38,25 +39,29 @@ type PostConfirmRequest struct {
 	CSRF      string `schema:"csrfToken"`
 }
 
-func MakeCardPayment(input string, environment config.Environment) error {
+// MakeCardPayment pays with the card details and reports the outcome of the journey, declined and
+// cancelled payments are outcomes rather than errors so that they can be expected. The payment is then
+// polled for up to verifyTimeout to check the API agrees with what the frontend showed. client is used for
+// every API request and sets the environment
+func MakeCardPayment(input string, details CardDetails, client *api.Client, verifyTimeout time.Duration) (CardPaymentResult, error) {
 	if strings.TrimSpace(input) == "" {
-		return errors.New("context is required to process a card payment, valid contexts are next_url and payment ID")
+		return CardPaymentResult{}, errors.New("context is required to process a card payment, valid contexts are next_url and payment ID")
 	}
-	nextURL, err := getNextURLFromInput(input, environment)
+	nextURL, err := getNextURLFromInput(input, client)
 	if err != nil {
-		return err
+		return CardPaymentResult{}, err
 	}
-	return processCardPayment(nextURL, environment)
+	return processCardPayment(nextURL, details, client, verifyTimeout)
 }
 
 // @TODO(sfount) this might be considered a hack -- talk to someone to sense check this
 // getNextURLFromInput parses a generic string input and returns a next url if it finds either a payment ID or a next url
-func getNextURLFromInput(input string, environment config.Environment) (string, error) {
+func getNextURLFromInput(input string, client *api.Client) (string, error) {
 	// assume a payment ID has been provided directly
 	if len(input) == 26 {
 		// @TODO(sfount) separating progress from actual methods would enable them to become generic if needed
 		s := StartProgress(fmt.Sprintf("Fetching next url for payment %s", aurora.Bold(aurora.Cyan(input))))
-		payment, err := api.GetPayment(input, environment)
+		payment, err := client.GetPayment(input)
 		ProgressSuccess(s)
 		if err != nil {
 			ProgressFail(s)
@@ -73,50 +78,178 @@ func getNextURLFromInput(input string, environment config.Environment) (string,
 
 type CardPaymentProcess struct {
 	Environment  config.Environment
+	Client       *api.Client
+	CardDetails  CardDetails
 	NextURL      string
 	CSRF         string
 	PaymentID    string
 	AuthAttempts int
+
+	// the page card details were submitted to, checked for a 3-D Secure challenge
+	page    *html.Node
+	pageURL *url.URL
+
+	// Outcome is set by the step that ends the journey
+	Outcome Outcome
+	Message string
+
+	// the payment as the API reported it after the journey
+	payment api.Payment
+
+	// Timings are recorded for each step that ran, FailedStep is the step that returned an error
+	Timings    []StepTiming
+	FailedStep string
+
+	// Quiet processes don't show progress, they are run concurrently by load tests
+	Quiet bool
+}
+
+// the steps of a card journey, in the order they run
+const (
+	StepCardDetailsPage = "card details page"
+	StepCardDetails     = "card details"
+	StepThreeDS         = "3-D Secure"
+	StepConfirmPage     = "confirm page"
+	StepConfirm         = "confirm"
+	StepVerify          = "verify"
+)
+
+type StepTiming struct {
+	Step     string
+	Duration time.Duration
 }
 
-func processCardPayment(nextURL string, environment config.Environment) error {
+func processCardPayment(nextURL string, details CardDetails, client *api.Client, verifyTimeout time.Duration) (CardPaymentResult, error) {
 	willWrite, _ := ShouldWriteProgress()
+	environment := client.Environment
+	process := CardPaymentProcess{
+		NextURL:     nextURL,
+		Environment: environment,
+		CardDetails: details,
+		Client:      client,
+	}
+	err := process.run(verifyTimeout)
+	if err != nil {
+		return process.result(), err
+	}
 
+	if !willWrite {
+		fmt.Print(process.PaymentID)
+		return process.result(), nil
+	}
+	if process.Outcome == OutcomeAuthorised {
+		fmt.Printf("> Completed card payment %s", aurora.Bold(fmt.Sprintf("%s/transactions/%s\n", environment.ToolboxURL(), process.PaymentID)))
+	} else {
+		fmt.Printf("> Card payment %s was %s: %s\n", aurora.Bold(aurora.Cyan(process.PaymentID)), aurora.Bold(process.Outcome), process.Message)
+	}
+	if process.payment.ID != "" {
+		card := process.payment.CardDetails
+		fmt.Printf("> Payment is %s, paid with %s ending %s\n", aurora.Bold(process.payment.State.Status), card.CardBrand, card.LastDigitsCardNumber)
+	}
+	return process.result(), nil
+}
+
+// run makes the journey with its own cookie jar, timing each step, then verifies the payment state
+func (process *CardPaymentProcess) run(verifyTimeout time.Duration) error {
 	// cookies are required for frontend authenticating each request
 	cookieJar, err := cookiejar.New(nil)
 	if err != nil {
 		return err
 	}
 	client := http.Client{
-		Jar: cookieJar,
+		Jar:       cookieJar,
+		Transport: common.TraceTransport(nil),
+	}
+	steps := []struct {
+		name string
+		run  func(http.Client) error
+	}{
+		{StepCardDetailsPage, process.getCardDetailsPage},
+		{StepCardDetails, process.postCardDetails},
+		{StepThreeDS, process.completeThreeDS},
+		{StepConfirmPage, process.getConfirmPage},
+		{StepConfirm, process.postConfirm},
+	}
+	for _, step := range steps {
+		err = process.time(step.name, func() error { return step.run(client) })
+		if err != nil {
+			return err
+		}
+		if process.Outcome != "" {
+			break
+		}
 	}
-	process := CardPaymentProcess{
-		NextURL:      nextURL,
-		Environment:  environment,
-		AuthAttempts: 0,
+	if _, verifiable := outcomeStates[process.Outcome]; !verifiable {
+		return nil
 	}
-	err = process.getCardDetailsPage(client)
+	return process.time(StepVerify, func() error { return process.verifyPayment(verifyTimeout) })
+}
+
+func (process *CardPaymentProcess) time(step string, run func() error) error {
+	start := time.Now()
+	err := run()
+	process.Timings = append(process.Timings, StepTiming{Step: step, Duration: time.Since(start)})
 	if err != nil {
-		return err
+		process.FailedStep = step
 	}
-	err = process.postCardDetails(client)
-	if err != nil {
-		return err
+	return err
+}
+
+// startProgress shows a spinner for a step unless the process is one of many running at once
+func (process *CardPaymentProcess) startProgress(message string) *spinner.Spinner {
+	if process.Quiet {
+		return spinner.New(spinner.CharSets[9], 100*time.Millisecond)
 	}
-	err = process.getConfirmPage(client)
-	if err != nil {
-		return err
+	return StartProgress(message)
+}
+
+func (process *CardPaymentProcess) result() CardPaymentResult {
+	return CardPaymentResult{
+		PaymentID:  process.PaymentID,
+		Outcome:    process.Outcome,
+		Message:    process.Message,
+		State:      process.payment.State.Status,
+		CardBrand:  process.payment.CardDetails.CardBrand,
+		LastDigits: process.payment.CardDetails.LastDigitsCardNumber,
 	}
-	err = process.postConfirm(client)
+}
+
+// verifyPayment polls the payment until the paying user's part is over, and fails if its state doesn't
+// match the outcome the frontend showed
+func (process *CardPaymentProcess) verifyPayment(timeout time.Duration) error {
+	states, verifiable := outcomeStates[process.Outcome]
+	if !verifiable || process.PaymentID == "" {
+		return nil
+	}
+	s := process.startProgress(fmt.Sprintf("Verifying payment %s", aurora.Bold(aurora.Cyan(process.PaymentID))))
+	payment, err := process.Client.WaitForOutcome(process.PaymentID, timeout)
 	if err != nil {
+		ProgressFail(s)
 		return err
 	}
-	if !willWrite {
-		fmt.Print(process.PaymentID)
-	} else {
-		fmt.Printf("> Completed card payment %s", aurora.Bold(fmt.Sprintf("https://toolbox.%s/transactions/%s\n", environment.BaseURL, process.PaymentID)))
+	process.payment = payment
+	for _, state := range states {
+		if payment.State.Status == state {
+			ProgressSuccess(s)
+			return nil
+		}
 	}
-	return nil
+	ProgressFail(s)
+	failure := ""
+	if payment.State.Code != "" {
+		failure = fmt.Sprintf(" (%s: %s)", payment.State.Code, payment.State.Message)
+	}
+	return fmt.Errorf("Card payment %s was %s on the frontend but the API reports it as %s%s", process.PaymentID, process.Outcome, payment.State.Status, failure)
+}
+
+// endJourney records the outcome if the page ends the card journey
+func (process *CardPaymentProcess) endJourney(document *html.Node) bool {
+	outcome, message, ended := ClassifyPage(document)
+	if ended {
+		process.Outcome = outcome
+		process.Message = message
+	}
+	return ended
 }
 
 func (process *CardPaymentProcess) getCardDetailsPage(client http.Client) error {
@@ -124,7 +257,7 @@ func (process *CardPaymentProcess) getCardDetailsPage(client http.Client) error
 	if err != nil {
 		return err
 	}
-	s := StartProgress("Loading card details page")
+	s := process.startProgress("Loading card details page")
 	res, err := client.Do(req)
 	if err != nil {
 		ProgressFail(s)
@@ -141,6 +274,10 @@ func (process *CardPaymentProcess) getCardDetailsPage(client http.Client) error
 	csrfToken, csrfFound := GetAttribute(csrfNode, "value")
 
 	if !csrfFound {
+		ProgressFail(s)
+		if process.endJourney(document) {
+			return nil
+		}
 		return errors.New("Unable to parse CSRF token from card details page")
 	}
 
@@ -158,12 +295,12 @@ func (process *CardPaymentProcess) getCardDetailsPage(client http.Client) error
 }
 
 func (process *CardPaymentProcess) getConfirmPage(client http.Client) error {
-	url := fmt.Sprintf("https://www.%s/card_details/%s/confirm", process.Environment.BaseURL, process.PaymentID)
+	url := fmt.Sprintf("%s/card_details/%s/confirm", process.Environment.FrontendURL(), process.PaymentID)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
 	}
-	s := StartProgress(fmt.Sprintf("Loading confirm page (%d)", process.AuthAttempts+1))
+	s := process.startProgress(fmt.Sprintf("Loading confirm page (%d)", process.AuthAttempts+1))
 	process.AuthAttempts = process.AuthAttempts + 1
 	res, err := client.Do(req)
 	if err != nil {
@@ -181,6 +318,9 @@ func (process *CardPaymentProcess) getConfirmPage(client http.Client) error {
 
 	if !csrfFound {
 		ProgressFail(s)
+		if process.endJourney(document) {
+			return nil
+		}
 		if process.AuthAttempts < 3 {
 			time.Sleep(500 * time.Millisecond)
 			return process.getConfirmPage(client)
@@ -193,23 +333,22 @@ func (process *CardPaymentProcess) getConfirmPage(client http.Client) error {
 	return nil
 }
 
-// post card details doesn't work with Worldpay 3ds enabled accounts
 func (process *CardPaymentProcess) postCardDetails(client http.Client) error {
-	url := fmt.Sprintf("https://www.%s/card_details/%s", process.Environment.BaseURL, process.PaymentID)
-	// @TODO(sfount) allow post params to be overriden by CLI flags
+	url := fmt.Sprintf("%s/card_details/%s", process.Environment.FrontendURL(), process.PaymentID)
+	details := process.CardDetails
 	postPaymentRequest := PostPaymentRequest{
 		PaymentID:       process.PaymentID,
 		CSRF:            process.CSRF,
-		CardNumber:      "4242424242424242",
-		CardExpiryMonth: "01",
-		CardExpiryYear:  "2030",
-		CardHolderName:  "Pay CLI User",
-		CardCVC:         "123",
-		AddressLineOne:  "10 Whitechapel High St",
-		AddressCity:     "London",
-		AddressCountry:  "GB",
-		AddressPostCode: "E18QS",
-		Email:           "pay@cli.gov.uk",
+		CardNumber:      details.Number,
+		CardExpiryMonth: details.ExpiryMonth,
+		CardExpiryYear:  details.ExpiryYear,
+		CardHolderName:  details.CardholderName,
+		CardCVC:         details.CVC,
+		AddressLineOne:  details.AddressLine1,
+		AddressCity:     details.AddressCity,
+		AddressCountry:  details.AddressCountry,
+		AddressPostCode: details.AddressPostcode,
+		Email:           details.Email,
 	}
 
 	err, form := postPaymentRequest.format()
@@ -217,7 +356,7 @@ func (process *CardPaymentProcess) postCardDetails(client http.Client) error {
 		return err
 	}
 
-	s := StartProgress("Submitting card details")
+	s := process.startProgress("Submitting card details")
 	res, err := client.PostForm(url, form)
 	if err != nil {
 		ProgressFail(s)
@@ -225,24 +364,37 @@ func (process *CardPaymentProcess) postCardDetails(client http.Client) error {
 	}
 	defer res.Body.Close()
 
+	// declined cards and invalid details are shown on the page the post ends on
+	document, err := html.Parse(res.Body)
+	if err != nil {
+		ProgressFail(s)
+		return err
+	}
+	if process.endJourney(document) {
+		ProgressFail(s)
+		return nil
+	}
 	if res.StatusCode != 200 {
 		ProgressFail(s)
 		return fmt.Errorf("Post card details returned non-success status code %d", res.StatusCode)
 	}
 	ProgressSuccess(s)
+	process.page = document
+	process.pageURL = res.Request.URL
 	return nil
 }
 
 func (process *CardPaymentProcess) postConfirm(client http.Client) error {
 	redirectClient := http.Client{
-		Jar: client.Jar,
+		Jar:       client.Jar,
+		Transport: client.Transport,
 
 		// return the successful redirect in favour of following it - invalid return URLs shouldn't block this process
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
 	}
-	url := fmt.Sprintf("https://www.%s/card_details/%s/confirm", process.Environment.BaseURL, process.PaymentID)
+	url := fmt.Sprintf("%s/card_details/%s/confirm", process.Environment.FrontendURL(), process.PaymentID)
 
 	postConfirmRequest := PostConfirmRequest{
 		PaymentID: process.PaymentID,
@@ -253,7 +405,7 @@ func (process *CardPaymentProcess) postConfirm(client http.Client) error {
 		return err
 	}
 
-	s := StartProgress("Submitting confirm payment")
+	s := process.startProgress("Submitting confirm payment")
 	res, err := redirectClient.PostForm(url, form)
 	if err != nil {
 		ProgressFail(s)
@@ -263,9 +415,14 @@ func (process *CardPaymentProcess) postConfirm(client http.Client) error {
 
 	if res.StatusCode != 303 {
 		ProgressFail(s)
+		document, err := html.Parse(res.Body)
+		if err == nil && process.endJourney(document) {
+			return nil
+		}
 		return fmt.Errorf("Post confirm payment returned non-success status code %d", res.StatusCode)
 	}
 	ProgressSuccess(s)
+	process.Outcome = OutcomeAuthorised
 	return nil
 }
 
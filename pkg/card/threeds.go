@@ -0,0 +1,242 @@
+package card
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"golang.org/x/net/html"
+)
+
+// ThreeDSResult is how the paying user answers a 3-D Secure challenge from their card issuer
+type ThreeDSResult string
+
+const (
+	ThreeDSPass    ThreeDSResult = "pass"
+	ThreeDSFail    ThreeDSResult = "fail"
+	ThreeDSAbandon ThreeDSResult = "abandon"
+)
+
+var ThreeDSResults = []ThreeDSResult{ThreeDSPass, ThreeDSFail, ThreeDSAbandon}
+
+// threeDSChoices are matched against the values and labels of the options on the issuer's challenge page,
+// simulators word these differently so unrecognised pages fail with the options that were found
+var threeDSChoices = map[ThreeDSResult][]string{
+	ThreeDSPass: {"pass", "y", "authenticated", "success"},
+	ThreeDSFail: {"fail", "n", "not_authenticated", "failure"},
+}
+
+// the fields of the forms that carry a challenge between the frontend and the issuer (3DS1 and 3DS2),
+// requests go to the issuer and responses come back to the frontend
+var threeDSRequestFields = []string{"PaReq", "creq"}
+
+var threeDSFields = append([]string{"PaRes", "cres"}, threeDSRequestFields...)
+
+// the most frontend and issuer pages passed through before the confirm page
+const maxThreeDSPages = 20
+
+var authWaitingDelay = 500 * time.Millisecond
+
+// ParseThreeDSResult checks a challenge result given on the command line
+func ParseThreeDSResult(value string) (ThreeDSResult, error) {
+	for _, result := range ThreeDSResults {
+		if string(result) == value {
+			return result, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown 3-D Secure result %s, expected pass, fail or abandon", value)
+}
+
+// completeThreeDS walks any 3-D Secure pages shown after card details are submitted: the forms that post the
+// challenge to the issuer and back, the issuer's challenge page and the frontend's auth_waiting page. Cards
+// that aren't challenged go straight on to the confirm page
+func (process *CardPaymentProcess) completeThreeDS(client http.Client) error {
+	var s *spinner.Spinner
+	fail := func() {
+		if s != nil {
+			ProgressFail(s)
+		}
+	}
+	// issuerPage is set when the last form posted the challenge request to the issuer's access control
+	// server, only that page can be the challenge
+	issuerPage := false
+	for pages := 0; pages < maxThreeDSPages; pages++ {
+		page, pageURL := process.page, process.pageURL
+		if page == nil {
+			return nil
+		}
+		if process.endJourney(page) {
+			fail()
+			return nil
+		}
+
+		var res *http.Response
+		var err error
+		if form, field := findChallengeForm(page); issuerPage && form != nil {
+			issuerPage = false
+			if process.CardDetails.ThreeDS == ThreeDSAbandon {
+				fail()
+				process.Outcome = OutcomeAbandoned
+				process.Message = "3-D Secure challenge abandoned"
+				return nil
+			}
+			var choice string
+			choice, err = chooseOption(page, field, process.CardDetails.ThreeDS)
+			if err == nil {
+				fieldName, _ := GetAttribute(field, "name")
+				res, err = submitForm(client, pageURL, form, url.Values{fieldName: {choice}})
+			}
+		} else if form := findThreeDSForm(page); form != nil {
+			if s == nil {
+				s = process.startProgress("Completing 3-D Secure challenge")
+			}
+			issuerPage = isChallengeRequest(form)
+			res, err = submitForm(client, pageURL, form, nil)
+		} else if issuerPage {
+			fail()
+			return fmt.Errorf("Unable to find a 3-D Secure challenge on the issuer page %q", pageTitle(page))
+		} else if strings.HasSuffix(pageURL.Path, "/auth_waiting") {
+			time.Sleep(authWaitingDelay)
+			res, err = client.Get(pageURL.String())
+		} else {
+			// anything else, such as the confirm page, is left to the next step
+			if s != nil {
+				ProgressSuccess(s)
+			}
+			return nil
+		}
+		if err != nil {
+			fail()
+			return err
+		}
+		process.page, err = html.Parse(res.Body)
+		res.Body.Close()
+		if err != nil {
+			fail()
+			return err
+		}
+		process.pageURL = res.Request.URL
+	}
+	fail()
+	return errors.New("Unable to complete the 3-D Secure challenge, too many pages were shown")
+}
+
+// findThreeDSForm finds a form that would be submitted by JavaScript, carrying the challenge between the
+// frontend and the issuer
+func findThreeDSForm(document *html.Node) *html.Node {
+	return FindElement(document, func(n *html.Node) bool {
+		return n.Data == "form" && hasInput(n, threeDSFields)
+	})
+}
+
+// isChallengeRequest is true for forms that post to the issuer, the page they lead to can be a challenge
+func isChallengeRequest(form *html.Node) bool {
+	return hasInput(form, threeDSRequestFields)
+}
+
+func hasInput(form *html.Node, names []string) bool {
+	return FindElement(form, func(input *html.Node) bool {
+		name, _ := GetAttribute(input, "name")
+		for _, field := range names {
+			if input.Data == "input" && strings.EqualFold(name, field) {
+				return true
+			}
+		}
+		return false
+	}) != nil
+}
+
+// findChallengeForm finds the issuer's challenge, a form asking the paying user to choose a result
+func findChallengeForm(document *html.Node) (*html.Node, *html.Node) {
+	var field *html.Node
+	form := FindElement(document, func(n *html.Node) bool {
+		if n.Data != "form" {
+			return false
+		}
+		field = FindElement(n, func(input *html.Node) bool {
+			inputType, _ := GetAttribute(input, "type")
+			return input.Data == "select" || (input.Data == "input" && inputType == "radio")
+		})
+		return field != nil
+	})
+	return form, field
+}
+
+// chooseOption picks the select option or radio button for the challenge result
+func chooseOption(page *html.Node, field *html.Node, result ThreeDSResult) (string, error) {
+	name, _ := GetAttribute(field, "name")
+	var candidates []*html.Node
+	if field.Data == "select" {
+		for option := field.FirstChild; option != nil; option = option.NextSibling {
+			if option.Type == html.ElementNode && option.Data == "option" {
+				candidates = append(candidates, option)
+			}
+		}
+	} else {
+		candidates = findAll(field.Parent.Parent, func(n *html.Node) bool {
+			candidateName, _ := GetAttribute(n, "name")
+			return n.Data == "input" && candidateName == name
+		})
+	}
+
+	var found []string
+	for _, candidate := range candidates {
+		value, _ := GetAttribute(candidate, "value")
+		for _, choice := range threeDSChoices[result] {
+			if strings.EqualFold(value, choice) || strings.EqualFold(GetText(candidate), choice) {
+				return value, nil
+			}
+		}
+		found = append(found, value)
+	}
+	return "", fmt.Errorf("Unable to find a %s option on the 3-D Secure challenge page %q, options were %s", result, pageTitle(page), strings.Join(found, ", "))
+}
+
+func pageTitle(document *html.Node) string {
+	return GetText(FindElement(document, func(n *html.Node) bool { return n.Data == "title" }))
+}
+
+// submitForm posts a form's inputs as a browser would, overrides replace the values of named fields
+func submitForm(client http.Client, pageURL *url.URL, form *html.Node, overrides url.Values) (*http.Response, error) {
+	action, _ := GetAttribute(form, "action")
+	target, err := pageURL.Parse(action)
+	if err != nil {
+		return nil, err
+	}
+
+	values := url.Values{}
+	for _, input := range findAll(form, func(n *html.Node) bool { return n.Data == "input" }) {
+		name, hasName := GetAttribute(input, "name")
+		inputType, _ := GetAttribute(input, "type")
+		if !hasName || inputType == "radio" || inputType == "submit" {
+			continue
+		}
+		value, _ := GetAttribute(input, "value")
+		values.Set(name, value)
+	}
+	for name, value := range overrides {
+		values[name] = value
+	}
+
+	method, _ := GetAttribute(form, "method")
+	if strings.EqualFold(method, "GET") {
+		target.RawQuery = values.Encode()
+		return client.Get(target.String())
+	}
+	return client.PostForm(target.String(), values)
+}
+
+func findAll(n *html.Node, matches func(*html.Node) bool) []*html.Node {
+	var found []*html.Node
+	if n.Type == html.ElementNode && matches(n) {
+		found = append(found, n)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		found = append(found, findAll(c, matches)...)
+	}
+	return found
+}
@@ -0,0 +1,64 @@
+package card
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/net/html"
+)
+
+var _ = Describe("Completing 3-D Secure challenges", func() {
+	var server *httptest.Server
+	var issuerPage string
+
+	BeforeEach(func() {
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, issuerPage)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	process := func(page string) *CardPaymentProcess {
+		document, err := html.Parse(strings.NewReader(page))
+		Expect(err).ShouldNot(HaveOccurred())
+		pageURL, err := url.Parse(server.URL + "/card_details/abc")
+		Expect(err).ShouldNot(HaveOccurred())
+		return &CardPaymentProcess{
+			CardDetails: CardDetails{ThreeDS: ThreeDSPass},
+			Quiet:       true,
+			page:        document,
+			pageURL:     pageURL,
+		}
+	}
+
+	client := func() http.Client {
+		jar, _ := cookiejar.New(nil)
+		return http.Client{Jar: jar}
+	}
+
+	Specify("A frontend page with a select should not be treated as a challenge", func() {
+		p := process(`<html><head><title>Confirm</title></head><body>
+			<form action="/card_details/abc/confirm"><select name="country"><option value="GB">United Kingdom</option></select></form>
+			</body></html>`)
+		Expect(p.completeThreeDS(client())).Should(Succeed())
+		Expect(p.Outcome).Should(BeEmpty())
+		Expect(p.pageURL.Path).Should(Equal("/card_details/abc"))
+	})
+
+	Specify("An issuer page without a recognised option should fail with its title", func() {
+		issuerPage = `<html><head><title>ACS Emulator</title></head><body>
+			<form action="/result"><select name="outcome"><option value="01">Option 1</option><option value="02">Option 2</option></select></form>
+			</body></html>`
+		p := process(`<html><body><form method="POST" action="/acs"><input name="creq" value="request"></form></body></html>`)
+		Expect(p.completeThreeDS(client())).Should(MatchError(`Unable to find a pass option on the 3-D Secure challenge page "ACS Emulator", options were 01, 02`))
+	})
+})
@@ -9,6 +9,7 @@ import (
 	"text/tabwriter"
 	"time"
 
+	"github.com/alphagov/pay-cli/pkg/common"
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 )
@@ -122,10 +123,11 @@ func formatState(state string) string {
 }
 
 func initClient() {
-	var tokenClient *http.Client
+	tokenClient := &http.Client{Transport: common.TraceTransport(nil)}
 	oauthToken := os.Getenv("PAY_CLI_GITHUB_ACCESS_TOKEN")
 	if oauthToken != "" {
-		ctx := context.Background()
+		// the token is added by the oauth2 transport on top of the traced client so it is redacted
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, tokenClient)
 		tokenSource := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: oauthToken},
 		)
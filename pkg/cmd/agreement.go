@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"github.com/alphagov/pay-cli/pkg/api"
+	"github.com/urfave/cli/v2"
+)
+
+// Agreement groups the recurring payment agreement endpoints
+func Agreement() *cli.Command {
+	return &cli.Command{
+		Name:   "agreement",
+		Usage:  "Manage agreements for recurring card payments",
+		Flags:  GlobalFlags,
+		Before: SetGlobalFlags,
+		Subcommands: []*cli.Command{
+			CreateAgreement(),
+			GetAgreement(),
+			SearchAgreements(),
+			CancelAgreement(),
+		},
+	}
+}
+
+func CreateAgreement() *cli.Command {
+	return &cli.Command{
+		Name:  "create",
+		Usage: "Create new agreement, set it up by creating a payment with --set-up-agreement",
+		Flags: append(
+			[]cli.Flag{
+				&cli.StringFlag{
+					Name:    "reference",
+					Aliases: []string{"r"},
+					Usage:   "Reference for the agreement, defaults to a random UUID",
+				},
+				&cli.StringFlag{
+					Name:    "description",
+					Aliases: []string{"d"},
+					Usage:   "Description of the agreement shown to the paying user",
+				},
+				&cli.StringFlag{
+					Name:    "user-identifier",
+					Aliases: []string{"u"},
+					Usage:   "Identifier for the paying user in your service",
+				},
+			},
+			GlobalFlags...,
+		),
+		Before: SetGlobalFlags,
+		Action: runCreateAgreementCmd,
+	}
+}
+
+func runCreateAgreementCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	agreementFlags := api.CreateAgreementRequest{
+		Reference:      context.String("reference"),
+		Description:    context.String("description"),
+		UserIdentifier: context.String("user-identifier"),
+	}
+	err = confirmLiveEnvironment(context, "create an agreement")
+	if err != nil {
+		return err
+	}
+	agreement, err := client.CreateAgreement(agreementFlags)
+	if err != nil {
+		return err
+	}
+	return agreement.ChainOut()
+}
+
+func GetAgreement() *cli.Command {
+	return &cli.Command{
+		Name:      "get",
+		Usage:     "Get one agreement",
+		ArgsUsage: "agreement-id",
+		Flags:     GlobalFlags,
+		Before:    SetGlobalFlags,
+		Action:    runGetAgreementCmd,
+	}
+}
+
+func runGetAgreementCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	ID, err := GetArgOrStdin(context)
+	if err != nil {
+		return err
+	}
+	agreement, err := client.GetAgreement(ID)
+	if err != nil {
+		return err
+	}
+	return agreement.ChainOut()
+}
+
+func SearchAgreements() *cli.Command {
+	return &cli.Command{
+		Name:  "search",
+		Usage: "Search agreements, outputs one agreement ID per line when piped",
+		Flags: append(
+			[]cli.Flag{
+				&cli.StringFlag{
+					Name:    "reference",
+					Aliases: []string{"r"},
+					Usage:   "Filter by agreement reference",
+				},
+				&cli.StringFlag{
+					Name:    "status",
+					Aliases: []string{"s"},
+					Usage:   "Filter by agreement status (created, active, cancelled, inactive, expired)",
+				},
+				&cli.IntFlag{
+					Name:  "limit",
+					Usage: "Maximum number of agreements to return, defaults to all matching agreements",
+				},
+			},
+			GlobalFlags...,
+		),
+		Before: SetGlobalFlags,
+		Action: runSearchAgreementsCmd,
+	}
+}
+
+func runSearchAgreementsCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	searchFlags := api.SearchAgreementsRequest{
+		Reference: context.String("reference"),
+		Status:    context.String("status"),
+	}
+	agreements, err := client.SearchAgreements(searchFlags, context.Int("limit"))
+	if err != nil {
+		return err
+	}
+	return agreements.ChainOut()
+}
+
+func CancelAgreement() *cli.Command {
+	return &cli.Command{
+		Name:      "cancel",
+		Usage:     "Cancel an active agreement, no further recurring payments can be taken against it",
+		ArgsUsage: "agreement-id",
+		Flags:     GlobalFlags,
+		Before:    SetGlobalFlags,
+		Action:    runCancelAgreementCmd,
+	}
+}
+
+func runCancelAgreementCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	ID, err := GetArgOrStdin(context)
+	if err != nil {
+		return err
+	}
+	err = confirmLiveEnvironment(context, "cancel agreement "+ID)
+	if err != nil {
+		return err
+	}
+	agreement, err := client.CancelAgreement(ID)
+	if err != nil {
+		return err
+	}
+	return agreement.ChainOut()
+}
@@ -2,11 +2,44 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/alphagov/pay-cli/pkg/api"
 	"github.com/urfave/cli/v2"
 )
 
+// newAPIClient initialises the environment selected by the global flags and returns a client for it
+func newAPIClient(context *cli.Context) (*api.Client, error) {
+	api.Output = api.OutputOptions{
+		Format:   GetGlobalFlag("output", context),
+		Template: GetGlobalFlag("template", context),
+	}
+	err := api.Output.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	Environment.Name = GetGlobalFlag("environment", context)
+	err = Environment.Init()
+	if err != nil {
+		return nil, err
+	}
+	client := api.NewClient(Environment)
+	client.Retries = GetGlobalInt("retries", context)
+	client.HTTPClient.Timeout = GetGlobalDuration("timeout", context)
+	client.DryRun = context.Bool("dry-run")
+	return client, nil
+}
+
+// dryRunFlag is shared by the commands that change payments
+var dryRunFlag = &cli.BoolFlag{
+	Name:  "dry-run",
+	Usage: "Print the request that would be sent as a curl command, with the API key redacted, without sending it",
+}
+
 // API is the top level command for the GOV.UK Pay api endpoints
 func API() *cli.Command {
 	return &cli.Command{
@@ -16,8 +49,16 @@ func API() *cli.Command {
 		Before: SetGlobalFlags,
 		Subcommands: []*cli.Command{
 			Get(),
+			Events(),
 			Create(),
 			Refund(),
+			Refunds(),
+			Search(),
+			Capture(),
+			Cancel(),
+			Agreement(),
+			Disputes(),
+			Wait(),
 		},
 	}
 }
@@ -34,17 +75,104 @@ func Create() *cli.Command {
 					Aliases: []string{"n"},
 					Usage:   "Output the next_url on payment create, will default to the external ID",
 				},
-				&cli.IntFlag{
+				&cli.StringFlag{
 					Name:    "amount",
 					Aliases: []string{"a"},
-					Usage:   "Amount for payment in pence",
+					Usage:   "Amount for payment with its unit, e.g. £12.50, 12.50GBP or 1250p (default: £20.00)",
+				},
+				&cli.StringFlag{
+					Name:    "reference",
+					Aliases: []string{"r"},
+					Usage:   "Reference for the payment, defaults to a random UUID, can be templated e.g. 'perf-{{.Index}}' or '{{.UUID}}' to make each of --count payments unique",
+				},
+				&cli.StringFlag{
+					Name:    "description",
+					Aliases: []string{"d"},
+					Usage:   "Description of the payment shown to the paying user",
+				},
+				&cli.StringFlag{
+					Name:  "return-url",
+					Usage: "URL the paying user is sent back to, defaults to the environment base URL",
 				},
 				&cli.StringFlag{
 					Name:    "language",
 					Aliases: []string{"l"},
-					Value:   "en",
-					Usage:   "Language of the payment",
+					Usage:   "Language of the payment, en or cy (default: en)",
+				},
+				&cli.StringFlag{
+					Name:  "email",
+					Usage: "Prefill the paying user's email address",
+				},
+				&cli.StringSliceFlag{
+					Name:    "metadata",
+					Aliases: []string{"m"},
+					Usage:   "Custom metadata as key=value, can be repeated",
+				},
+				&cli.BoolFlag{
+					Name:  "moto",
+					Usage: "Create a MOTO (mail order, telephone order) payment",
+				},
+				&cli.BoolFlag{
+					Name:  "delayed-capture",
+					Usage: "Create the payment with delayed capture, it will stop at capturable until captured or cancelled",
+				},
+				&cli.StringFlag{
+					Name:  "cardholder-name",
+					Usage: "Prefill the cardholder name",
+				},
+				&cli.StringFlag{
+					Name:  "billing-line1",
+					Usage: "Prefill the first line of the billing address",
+				},
+				&cli.StringFlag{
+					Name:  "billing-line2",
+					Usage: "Prefill the second line of the billing address",
+				},
+				&cli.StringFlag{
+					Name:  "billing-postcode",
+					Usage: "Prefill the billing address postcode",
+				},
+				&cli.StringFlag{
+					Name:  "billing-city",
+					Usage: "Prefill the billing address city",
+				},
+				&cli.StringFlag{
+					Name:  "billing-country",
+					Usage: "Prefill the billing address country as a 2 letter ISO code",
+				},
+				&cli.StringFlag{
+					Name:  "set-up-agreement",
+					Usage: "Agreement ID to set up for recurring payments using this payment",
+				},
+				&cli.StringFlag{
+					Name:  "agreement-id",
+					Usage: "Agreement ID to take a recurring payment against",
+				},
+				&cli.StringFlag{
+					Name:  "authorisation-mode",
+					Usage: "How the payment will be authorised (web, moto_api, agreement), agreement payments are taken immediately against --agreement-id",
+				},
+				&cli.StringFlag{
+					Name:    "from-file",
+					Aliases: []string{"f"},
+					Usage:   "JSON or YAML create payment request body, flags take precedence over values in the file",
 				},
+				&cli.IntFlag{
+					Name:    "count",
+					Aliases: []string{"c"},
+					Value:   1,
+					Usage:   "Number of payments to create",
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Value: 1,
+					Usage: "Number of payments to create in parallel when --count is more than 1",
+				},
+				&cli.Float64Flag{
+					Name:  "rate",
+					Usage: "Maximum number of payments to create per second when --count is more than 1, defaults to unlimited",
+				},
+				dryRunFlag,
 			},
 			GlobalFlags...,
 		),
@@ -55,16 +183,158 @@ func Create() *cli.Command {
 
 func runCreateCmd(context *cli.Context) error {
 	shouldOutputNextURL := context.Bool("output-next-url")
-	Environment.Name = GetGlobalFlag("environment", context)
-	err := Environment.Init()
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	amount, err := amountFlag(context, "amount")
+	if err != nil {
+		return err
+	}
+	// an amount of 0 would be treated as unset and replaced with the default
+	if context.IsSet("amount") && amount < 1 {
+		return fmt.Errorf("Invalid amount %s, payments must be for at least 1p", context.String("amount"))
+	}
+	metadata, err := api.ParseMetadata(context.StringSlice("metadata"))
 	if err != nil {
 		return err
 	}
 	paymentFlags := api.CreatePaymentRequest{
-		Amount:   context.Int("amount"),
-		Language: context.String("language"),
+		Amount:                     amount,
+		Reference:                  context.String("reference"),
+		Description:                context.String("description"),
+		ReturnURL:                  context.String("return-url"),
+		Language:                   context.String("language"),
+		Email:                      context.String("email"),
+		Metadata:                   metadata,
+		Moto:                       context.Bool("moto"),
+		DelayedCapture:             context.Bool("delayed-capture"),
+		PrefilledCardholderDetails: prefilledCardholderDetailsFromFlags(context),
+		SetUpAgreement:             context.String("set-up-agreement"),
+		AgreementID:                context.String("agreement-id"),
+		AuthorisationMode:          context.String("authorisation-mode"),
+	}
+	if path := context.String("from-file"); path != "" {
+		fileRequest, err := api.ReadCreatePaymentRequest(path)
+		if err != nil {
+			return err
+		}
+		err = api.Replace(fileRequest, &paymentFlags)
+		if err != nil {
+			return err
+		}
+	}
+	if shouldOutputNextURL && paymentFlags.AuthorisationMode == api.AuthorisationModeAgreement {
+		return errors.New("Payments with authorisation mode agreement are taken without the paying user and have no next_url")
+	}
+	count := context.Int("count")
+	if count > 1 && client.DryRun {
+		return errors.New("--dry-run prints a single request and can't be used with --count")
+	}
+	action := "create a payment"
+	if count > 1 {
+		action = fmt.Sprintf("create %d payments", count)
+	} else {
+		paymentFlags.Reference, err = api.RenderReference(paymentFlags.Reference, 1)
+		if err != nil {
+			return err
+		}
+	}
+	err = confirmLiveEnvironment(context, action)
+	if err != nil {
+		return err
+	}
+	if count > 1 {
+		return runBulkCreate(context, client, paymentFlags, shouldOutputNextURL)
+	}
+	payment, err := client.CreatePayment(paymentFlags)
+	if errors.Is(err, api.ErrDryRun) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return payment.ChainOut(shouldOutputNextURL)
+}
+
+// runBulkCreate streams one ID (or next url) per line as payments are created when piped, the summary
+// is written to stderr in that case so it doesn't pollute the pipe. An explicit output format writes
+// all of the created payments once they have finished
+func runBulkCreate(context *cli.Context, client *api.Client, request api.CreatePaymentRequest, shouldOutputNextURL bool) error {
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return err
+	}
+	isPiped := (fi.Mode() & os.ModeCharDevice) == 0
+
+	options := api.BulkCreateOptions{
+		Count:         context.Int("count"),
+		Concurrency:   context.Int("concurrency"),
+		RatePerSecond: context.Float64("rate"),
+	}
+	var created api.Payments
+	if !api.Output.IsDefault() {
+		options.OnCreated = func(payment api.Payment) {
+			created = append(created, payment)
+		}
+	} else if isPiped {
+		options.OnCreated = func(payment api.Payment) {
+			if shouldOutputNextURL {
+				fmt.Println(payment.Links.NextURL.Href)
+			} else {
+				fmt.Println(payment.ID)
+			}
+		}
+	}
+
+	result, err := client.BulkCreatePayments(request, options)
+	if err != nil {
+		return err
+	}
+	if isPiped || !api.Output.IsDefault() {
+		result.Summarise(os.Stderr)
+	} else {
+		result.Summarise(os.Stdout)
+	}
+	if !api.Output.IsDefault() {
+		err = created.ChainOut()
+		if err != nil {
+			return err
+		}
+	}
+	if result.Created < result.Requested {
+		return fmt.Errorf("%d of %d payments failed to create", result.Requested-result.Created, result.Requested)
+	}
+	return nil
+}
+
+// amountFlag parses an amount flag into pence, unset flags are 0 so that defaults can be applied
+func amountFlag(context *cli.Context, name string) (int, error) {
+	if !context.IsSet(name) {
+		return 0, nil
+	}
+	return api.ParseAmount(context.String(name))
+}
+
+// prefilledCardholderDetailsFromFlags returns nil when no prefill flags are set so they can be omitted from the request
+func prefilledCardholderDetailsFromFlags(context *cli.Context) *api.PrefilledCardholderDetails {
+	address := api.BillingAddress{
+		Line1:    context.String("billing-line1"),
+		Line2:    context.String("billing-line2"),
+		Postcode: context.String("billing-postcode"),
+		City:     context.String("billing-city"),
+		Country:  context.String("billing-country"),
+	}
+	details := api.PrefilledCardholderDetails{
+		CardholderName: context.String("cardholder-name"),
 	}
-	return api.CreatePayment(Environment, paymentFlags, shouldOutputNextURL)
+	if address != (api.BillingAddress{}) {
+		details.BillingAddress = &address
+	}
+	if details == (api.PrefilledCardholderDetails{}) {
+		return nil
+	}
+	return &details
 }
 
 func Get() *cli.Command {
@@ -78,8 +348,7 @@ func Get() *cli.Command {
 }
 
 func runGetCmd(context *cli.Context) error {
-	Environment.Name = GetGlobalFlag("environment", context)
-	err := Environment.Init()
+	client, err := newAPIClient(context)
 	if err != nil {
 		return err
 	}
@@ -87,36 +356,84 @@ func runGetCmd(context *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	payment, err := api.GetPayment(ID, Environment)
+	payment, err := client.GetPayment(ID)
 	if err != nil {
 		return err
 	}
-	payment.ChainOut(false)
-	return nil
+	return payment.ChainOut(false)
+}
+
+func Events() *cli.Command {
+	return &cli.Command{
+		Name:      "events",
+		Usage:     "Show the timeline of state transitions for a payment",
+		ArgsUsage: "payment-id",
+		Flags:     GlobalFlags,
+		Before:    SetGlobalFlags,
+		Action:    runEventsCmd,
+	}
+}
+
+func runEventsCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	ID, err := GetArgOrStdin(context)
+	if err != nil {
+		return err
+	}
+	events, err := client.GetPaymentEvents(ID)
+	if err != nil {
+		return err
+	}
+	return events.ChainOut()
 }
 
 func Refund() *cli.Command {
 	return &cli.Command{
-		Name:  "refund",
-		Usage: "Refund payment",
+		Name:      "refund",
+		Usage:     "Refund payment",
+		ArgsUsage: "payment-id",
 		Flags: append(
 			[]cli.Flag{
-				&cli.IntFlag{
+				&cli.StringFlag{
 					Name:    "amount",
 					Aliases: []string{"a"},
-					Usage:   "Amount to be refunded in pence",
+					Usage:   "Amount to be refunded with its unit, e.g. £12.50, 12.50GBP or 1250p",
+				},
+				&cli.BoolFlag{
+					Name:  "full",
+					Usage: "Refund the full amount still available to refund",
+				},
+				&cli.StringFlag{
+					Name:  "expected-available",
+					Usage: "Only refund if this amount is available to refund, e.g. £20.00, guards against concurrent refunds",
 				},
+				&cli.BoolFlag{
+					Name:    "wait",
+					Aliases: []string{"w"},
+					Usage:   "Wait until the refund has reached a terminal status (success or error)",
+				},
+				&cli.DurationFlag{
+					Name:  "wait-timeout",
+					Value: 2 * time.Minute,
+					Usage: "How long to wait for the refund to reach a terminal status",
+				},
+				dryRunFlag,
 			},
 			GlobalFlags...,
 		),
 		Before: SetGlobalFlags,
 		Action: runRefundCmd,
+		Subcommands: []*cli.Command{
+			GetRefund(),
+		},
 	}
 }
 
 func runRefundCmd(context *cli.Context) error {
-	Environment.Name = GetGlobalFlag("environment", context)
-	err := Environment.Init()
+	client, err := newAPIClient(context)
 	if err != nil {
 		return err
 	}
@@ -124,9 +441,449 @@ func runRefundCmd(context *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	amount := context.Int("amount")
-	if amount == 0 {
-		return errors.New("Amount (--amount, -a) is required to refund a payment")
+	full := context.Bool("full")
+	if full == context.IsSet("amount") {
+		return errors.New("Either an amount (--amount, -a) or --full is required to refund a payment")
+	}
+	amount, err := amountFlag(context, "amount")
+	if err != nil {
+		return err
+	}
+	check := api.RefundCheck{
+		Amount: amount,
+		Full:   full,
+	}
+	if context.IsSet("expected-available") {
+		expectedAvailable, err := amountFlag(context, "expected-available")
+		if err != nil {
+			return err
+		}
+		check.ExpectedAvailable = &expectedAvailable
+	}
+	request, err := client.PrepareRefund(ID, check)
+	if err != nil {
+		return err
+	}
+	err = confirmLiveEnvironment(context, fmt.Sprintf("refund %s on payment %s", api.FormatAmount(request.Amount), ID))
+	if err != nil {
+		return err
+	}
+	refund, err := client.RefundPayment(ID, request)
+	if errors.Is(err, api.ErrDryRun) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if context.Bool("wait") {
+		refund, err = client.WaitForRefund(ID, refund.ID, context.Duration("wait-timeout"))
+		if err != nil {
+			return err
+		}
+	}
+	return refund.ChainOut()
+}
+
+func GetRefund() *cli.Command {
+	return &cli.Command{
+		Name:      "get",
+		Usage:     "Get one refund, the refund ID can be piped in",
+		ArgsUsage: "payment-id refund-id",
+		Flags:     GlobalFlags,
+		Before:    SetGlobalFlags,
+		Action:    runGetRefundCmd,
+	}
+}
+
+func runGetRefundCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	paymentID, refundID, err := getRefundIDs(context)
+	if err != nil {
+		return err
+	}
+	refund, err := client.GetRefund(paymentID, refundID)
+	if err != nil {
+		return err
+	}
+	return refund.ChainOut()
+}
+
+// getRefundIDs reads the payment ID argument and the refund ID, which is either the second argument or piped in
+func getRefundIDs(context *cli.Context) (string, string, error) {
+	paymentID := context.Args().Get(0)
+	refundID := context.Args().Get(1)
+	if refundID == "" {
+		var err error
+		refundID, err = GetArgOrStdin(context)
+		if err != nil {
+			return "", "", err
+		}
+		// without a pipe GetArgOrStdin falls back to the payment ID argument
+		if refundID == paymentID {
+			return "", "", errors.New("Both a payment ID and a refund ID are required to get a refund")
+		}
+	}
+	return paymentID, refundID, nil
+}
+
+func Refunds() *cli.Command {
+	return &cli.Command{
+		Name:      "refunds",
+		Usage:     "List the refunds for a payment",
+		ArgsUsage: "payment-id",
+		Flags:     GlobalFlags,
+		Before:    SetGlobalFlags,
+		Action:    runRefundsCmd,
+		Subcommands: []*cli.Command{
+			SearchRefunds(),
+		},
+	}
+}
+
+func SearchRefunds() *cli.Command {
+	return &cli.Command{
+		Name:  "search",
+		Usage: "Search refunds across the account, outputs one refund ID per line when piped",
+		Flags: append(
+			[]cli.Flag{
+				&cli.StringFlag{
+					Name:  "from-date",
+					Usage: "Only include refunds created on or after this date (YYYY-MM-DD or RFC 3339)",
+				},
+				&cli.StringFlag{
+					Name:  "to-date",
+					Usage: "Only include refunds created before this date (YYYY-MM-DD or RFC 3339)",
+				},
+				&cli.StringFlag{
+					Name:  "from-settled-date",
+					Usage: "Only include refunds settled on or after this date (YYYY-MM-DD)",
+				},
+				&cli.StringFlag{
+					Name:  "to-settled-date",
+					Usage: "Only include refunds settled on or before this date (YYYY-MM-DD)",
+				},
+			},
+			GlobalFlags...,
+		),
+		Before: SetGlobalFlags,
+		Action: runSearchRefundsCmd,
+	}
+}
+
+func runSearchRefundsCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	fromDate, err := api.FormatSearchDate(context.String("from-date"))
+	if err != nil {
+		return err
+	}
+	toDate, err := api.FormatSearchDate(context.String("to-date"))
+	if err != nil {
+		return err
+	}
+	searchFlags := api.SearchRefundsRequest{
+		FromDate:        fromDate,
+		ToDate:          toDate,
+		FromSettledDate: context.String("from-settled-date"),
+		ToSettledDate:   context.String("to-settled-date"),
+	}
+	refunds, err := client.SearchRefunds(searchFlags)
+	if err != nil {
+		return err
+	}
+	return refunds.ChainOut()
+}
+
+func runRefundsCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	ID, err := GetArgOrStdin(context)
+	if err != nil {
+		return err
+	}
+	refunds, err := client.GetRefundsForPayment(ID)
+	if err != nil {
+		return err
+	}
+	return refunds.ChainOut()
+}
+
+func Search() *cli.Command {
+	return &cli.Command{
+		Name:  "search",
+		Usage: "Search payments, outputs one payment ID per line when piped",
+		Flags: append(
+			[]cli.Flag{
+				&cli.StringFlag{
+					Name:    "reference",
+					Aliases: []string{"r"},
+					Usage:   "Filter by payment reference",
+				},
+				&cli.StringFlag{
+					Name:  "email",
+					Usage: "Filter by email address of the paying user",
+				},
+				&cli.StringFlag{
+					Name:    "state",
+					Aliases: []string{"s"},
+					Usage:   "Filter by payment state (created, started, submitted, capturable, success, failed, cancelled, error)",
+				},
+				&cli.StringFlag{
+					Name:  "card-brand",
+					Usage: "Filter by card brand, e.g. visa, master-card",
+				},
+				&cli.StringFlag{
+					Name:  "last-digits",
+					Usage: "Filter by the last 4 digits of the card number",
+				},
+				&cli.StringFlag{
+					Name:  "first-digits",
+					Usage: "Filter by the first 6 digits of the card number",
+				},
+				&cli.StringFlag{
+					Name:  "cardholder-name",
+					Usage: "Filter by cardholder name",
+				},
+				&cli.StringFlag{
+					Name:  "from-date",
+					Usage: "Only include payments created on or after this date (YYYY-MM-DD or RFC 3339)",
+				},
+				&cli.StringFlag{
+					Name:  "to-date",
+					Usage: "Only include payments created before this date (YYYY-MM-DD or RFC 3339)",
+				},
+				&cli.IntFlag{
+					Name:  "limit",
+					Usage: "Maximum number of payments to return, defaults to all matching payments",
+				},
+			},
+			GlobalFlags...,
+		),
+		Before: SetGlobalFlags,
+		Action: runSearchCmd,
+	}
+}
+
+func runSearchCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	fromDate, err := api.FormatSearchDate(context.String("from-date"))
+	if err != nil {
+		return err
+	}
+	toDate, err := api.FormatSearchDate(context.String("to-date"))
+	if err != nil {
+		return err
+	}
+	searchFlags := api.SearchPaymentsRequest{
+		Reference:             context.String("reference"),
+		Email:                 context.String("email"),
+		State:                 context.String("state"),
+		CardBrand:             context.String("card-brand"),
+		LastDigitsCardNumber:  context.String("last-digits"),
+		FirstDigitsCardNumber: context.String("first-digits"),
+		CardholderName:        context.String("cardholder-name"),
+		FromDate:              fromDate,
+		ToDate:                toDate,
+	}
+	payments, err := client.SearchPayments(searchFlags, context.Int("limit"))
+	if err != nil {
+		return err
+	}
+	return payments.ChainOut()
+}
+
+func Capture() *cli.Command {
+	return &cli.Command{
+		Name:   "capture",
+		Usage:  "Capture a delayed capture payment",
+		Flags:  append([]cli.Flag{dryRunFlag}, GlobalFlags...),
+		Before: SetGlobalFlags,
+		Action: runCaptureCmd,
+	}
+}
+
+func runCaptureCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	ID, err := GetArgOrStdin(context)
+	if err != nil {
+		return err
+	}
+	err = confirmLiveEnvironment(context, "capture payment "+ID)
+	if err != nil {
+		return err
+	}
+	payment, err := client.CapturePayment(ID)
+	if errors.Is(err, api.ErrDryRun) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return payment.StateChainOut()
+}
+
+func Cancel() *cli.Command {
+	return &cli.Command{
+		Name:   "cancel",
+		Usage:  "Cancel a payment that has not finished",
+		Flags:  append([]cli.Flag{dryRunFlag}, GlobalFlags...),
+		Before: SetGlobalFlags,
+		Action: runCancelCmd,
+	}
+}
+
+func runCancelCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	ID, err := GetArgOrStdin(context)
+	if err != nil {
+		return err
+	}
+	err = confirmLiveEnvironment(context, "cancel payment "+ID)
+	if err != nil {
+		return err
+	}
+	payment, err := client.CancelPayment(ID)
+	if errors.Is(err, api.ErrDryRun) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return payment.StateChainOut()
+}
+
+func Wait() *cli.Command {
+	return &cli.Command{
+		Name:        "wait",
+		Usage:       "Wait until a payment reaches a state",
+		Description: "Polls the payment with backoff until it reaches --state, --wait-timeout limits how long to wait in total and the global --timeout limits each request, so --timeout is rejected when it's given to wait itself. Exits with an error showing the last seen state if the payment finishes in a different state or the wait times out",
+		ArgsUsage:   "payment-id",
+		Flags: append(
+			[]cli.Flag{
+				&cli.StringFlag{
+					Name:     "state",
+					Aliases:  []string{"s"},
+					Required: true,
+					Usage:    fmt.Sprintf("State to wait for (%s)", strings.Join(api.PaymentStates, ", ")),
+				},
+				&cli.DurationFlag{
+					Name:  "wait-timeout",
+					Value: time.Minute,
+					Usage: "How long to wait for the payment to reach the state",
+				},
+			},
+			GlobalFlags...,
+		),
+		Before: SetGlobalFlags,
+		Action: runWaitCmd,
+	}
+}
+
+func runWaitCmd(context *cli.Context) error {
+	// --timeout is easily mistaken for the overall wait, only accept it where it's clearly global
+	for _, name := range context.LocalFlagNames() {
+		if name == "timeout" {
+			return errors.New("--timeout limits each API request, use --wait-timeout to set how long to wait for the payment to reach the state")
+		}
+	}
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	ID, err := GetArgOrStdin(context)
+	if err != nil {
+		return err
+	}
+	payment, err := client.WaitForPayment(ID, context.String("state"), context.Duration("wait-timeout"))
+	if err != nil {
+		return err
+	}
+	return payment.StateChainOut()
+}
+
+func Disputes() *cli.Command {
+	return &cli.Command{
+		Name:  "disputes",
+		Usage: "Search disputes, outputs one dispute ID per line when piped",
+		Flags: append(
+			[]cli.Flag{
+				&cli.StringFlag{
+					Name:    "payment-id",
+					Aliases: []string{"p"},
+					Usage:   "Only include disputes raised against this payment, the API can't filter by payment so every page of disputes for the account is fetched unless --limit is reached first, narrow the search with the date flags on large accounts",
+				},
+				&cli.StringFlag{
+					Name:    "status",
+					Aliases: []string{"s"},
+					Usage:   "Filter by dispute status (needs_response, under_review, won, lost)",
+				},
+				&cli.StringFlag{
+					Name:  "from-date",
+					Usage: "Only include disputes created on or after this date (YYYY-MM-DD or RFC 3339)",
+				},
+				&cli.StringFlag{
+					Name:  "to-date",
+					Usage: "Only include disputes created before this date (YYYY-MM-DD or RFC 3339)",
+				},
+				&cli.StringFlag{
+					Name:  "from-settled-date",
+					Usage: "Only include disputes settled on or after this date (YYYY-MM-DD)",
+				},
+				&cli.StringFlag{
+					Name:  "to-settled-date",
+					Usage: "Only include disputes settled on or before this date (YYYY-MM-DD)",
+				},
+				&cli.IntFlag{
+					Name:  "limit",
+					Usage: "Maximum number of disputes to return, defaults to all matching disputes",
+				},
+			},
+			GlobalFlags...,
+		),
+		Before: SetGlobalFlags,
+		Action: runDisputesCmd,
+	}
+}
+
+func runDisputesCmd(context *cli.Context) error {
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	fromDate, err := api.FormatSearchDate(context.String("from-date"))
+	if err != nil {
+		return err
+	}
+	toDate, err := api.FormatSearchDate(context.String("to-date"))
+	if err != nil {
+		return err
+	}
+	searchFlags := api.SearchDisputesRequest{
+		Status:          context.String("status"),
+		FromDate:        fromDate,
+		ToDate:          toDate,
+		FromSettledDate: context.String("from-settled-date"),
+		ToSettledDate:   context.String("to-settled-date"),
+	}
+	disputes, err := client.SearchDisputes(searchFlags, context.String("payment-id"), context.Int("limit"))
+	if err != nil {
+		return err
 	}
-	return api.RefundPayment(ID, amount, Environment)
+	return disputes.ChainOut()
 }
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/cli/v2"
+)
+
+var _ = Describe("Getting a refund", func() {
+	var stdin *os.File
+	var paymentID, refundID string
+
+	// run pipes input to the command like a shell would, so GetArgOrStdin reads it
+	run := func(input string, args ...string) error {
+		reader, writer, err := os.Pipe()
+		Expect(err).ShouldNot(HaveOccurred())
+		writer.WriteString(input)
+		writer.Close()
+		stdin, os.Stdin = os.Stdin, reader
+		defer func() {
+			os.Stdin = stdin
+			reader.Close()
+		}()
+
+		app := cli.NewApp()
+		app.Commands = []*cli.Command{{
+			Name: "get",
+			Action: func(context *cli.Context) (err error) {
+				paymentID, refundID, err = getRefundIDs(context)
+				return err
+			},
+		}}
+		return app.Run(append([]string{"pay", "get"}, args...))
+	}
+
+	BeforeEach(func() {
+		paymentID, refundID = "", ""
+	})
+
+	Specify("Both IDs should be read from the arguments", func() {
+		Expect(run("", "p1", "r1")).Should(Succeed())
+		Expect(paymentID).Should(Equal("p1"))
+		Expect(refundID).Should(Equal("r1"))
+	})
+
+	Specify("The refund ID should be read from a pipe", func() {
+		Expect(run("r1\n", "p1")).Should(Succeed())
+		Expect(paymentID).Should(Equal("p1"))
+		Expect(refundID).Should(Equal("r1"))
+	})
+
+	Specify("The payment ID should not be used as the refund ID", func() {
+		Expect(run("p1\n", "p1")).Should(MatchError("Both a payment ID and a refund ID are required to get a refund"))
+	})
+})
@@ -1,38 +1,200 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/api"
 	"github.com/alphagov/pay-cli/pkg/card"
 	"github.com/urfave/cli/v2"
 )
 
 func Card() *cli.Command {
 	return &cli.Command{
-		Name:      "card",
-		Usage:     "Process a card payment, valid contexts are next_url and payment ID",
-		Flags:     GlobalFlags,
+		Name:  "card",
+		Usage: "Process a card payment, valid contexts are next_url and payment ID",
+		Flags: append(
+			[]cli.Flag{
+				&cli.StringFlag{
+					Name:  "card",
+					Usage: fmt.Sprintf("Named test card to pay with (%s), or a preset configured for the environment under [<environment>.cards.<name>]", strings.Join(cardPresetNames(), ", ")),
+				},
+				&cli.StringFlag{
+					Name:  "card-number",
+					Usage: "Card number, overrides the preset",
+				},
+				&cli.StringFlag{
+					Name:  "expiry",
+					Usage: "Card expiry date as MM/YY or MM/YYYY",
+				},
+				&cli.StringFlag{
+					Name:  "cvc",
+					Usage: "Card security code",
+				},
+				&cli.StringFlag{
+					Name:  "cardholder-name",
+					Usage: "Name on the card",
+				},
+				&cli.StringFlag{
+					Name:  "address-line1",
+					Usage: "First line of the billing address",
+				},
+				&cli.StringFlag{
+					Name:  "address-city",
+					Usage: "Billing address city",
+				},
+				&cli.StringFlag{
+					Name:  "address-postcode",
+					Usage: "Billing address postcode",
+				},
+				&cli.StringFlag{
+					Name:  "address-country",
+					Usage: "Billing address country code, e.g. GB",
+				},
+				&cli.StringFlag{
+					Name:  "email",
+					Usage: "Email address of the paying user",
+				},
+				&cli.StringFlag{
+					Name:  "3ds",
+					Usage: "How to answer a 3-D Secure challenge if the card is challenged (pass, fail, abandon)",
+				},
+				&cli.StringFlag{
+					Name:  "expect",
+					Value: string(card.OutcomeAuthorised),
+					Usage: "Outcome the journey must end with to succeed (authorised, declined, error, cancelled, validation-failed, abandoned)",
+				},
+				&cli.DurationFlag{
+					Name:  "verify-timeout",
+					Value: time.Minute,
+					Usage: "How long to wait for the API to report the payment's final state after the journey",
+				},
+				&cli.IntFlag{
+					Name:    "count",
+					Aliases: []string{"c"},
+					Value:   1,
+					Usage:   "Number of card journeys to make, more than 1 creates a payment for each journey and reports step latencies as a load test",
+				},
+				&cli.IntFlag{
+					Name:  "concurrency",
+					Value: 1,
+					Usage: "Number of card journeys to make in parallel when --count is more than 1",
+				},
+				&cli.StringFlag{
+					Name:  "csv",
+					Usage: "Write the timings of each card journey to this file when --count is more than 1",
+				},
+			},
+			GlobalFlags...,
+		),
 		Action:    runCardCmd,
-		ArgsUsage: "context",
+		ArgsUsage: "[context]",
 		Before:    SetGlobalFlags,
 	}
 }
 
 func runCardCmd(context *cli.Context) error {
-	nextURL, err := GetArgOrStdin(context)
+	// the shared client applies --retries, --timeout and --trace to every API request in the journey
+	client, err := newAPIClient(context)
+	if err != nil {
+		return err
+	}
+	if Environment.IsLive() {
+		return fmt.Errorf("Refusing to make a card payment in live environment %s, test cards can only be used in test environments", Environment.DisplayName())
+	}
+
+	expected, err := card.ParseOutcome(context.String("expect"))
 	if err != nil {
 		return err
 	}
+	details, err := cardDetailsFromFlags(context)
+	if err != nil {
+		return err
+	}
+	if context.Int("count") > 1 {
+		return runCardLoadTest(context, client, details, expected)
+	}
 
-	// @TODO(sfount) move to helper method
-	Environment.Name = GetGlobalFlag("environment", context)
-	apiKey, err := Environment.GetAPIKey()
+	nextURL, err := GetArgOrStdin(context)
+	if err != nil {
+		return err
+	}
+	result, err := card.MakeCardPayment(nextURL, details, client, context.Duration("verify-timeout"))
 	if err != nil {
 		return err
 	}
-	baseURL, err := Environment.GetBaseURL()
+	return result.Expect(expected)
+}
+
+// runCardLoadTest creates a payment for each journey so no context is needed, the summary is written once
+// every journey has finished
+func runCardLoadTest(context *cli.Context, client *api.Client, details card.CardDetails, expected card.Outcome) error {
+	result, err := card.RunLoadTest(client, card.LoadTestOptions{
+		Count:         context.Int("count"),
+		Concurrency:   context.Int("concurrency"),
+		Details:       details,
+		Expected:      expected,
+		VerifyTimeout: context.Duration("verify-timeout"),
+	})
 	if err != nil {
 		return err
 	}
-	Environment.APIKey = apiKey
-	Environment.BaseURL = baseURL
-	return card.MakeCardPayment(nextURL, Environment)
+	result.Summarise(os.Stdout)
+
+	if path := context.String("csv"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		err = result.WriteCSV(file)
+		if err != nil {
+			return err
+		}
+	}
+	if result.Succeeded < result.Requested {
+		return fmt.Errorf("%d of %d card journeys failed", result.Requested-result.Succeeded, result.Requested)
+	}
+	return nil
+}
+
+func cardDetailsFromFlags(context *cli.Context) (card.CardDetails, error) {
+	flags := card.CardDetails{
+		Number:          context.String("card-number"),
+		CVC:             context.String("cvc"),
+		CardholderName:  context.String("cardholder-name"),
+		AddressLine1:    context.String("address-line1"),
+		AddressCity:     context.String("address-city"),
+		AddressPostcode: context.String("address-postcode"),
+		AddressCountry:  context.String("address-country"),
+		Email:           context.String("email"),
+	}
+	if context.IsSet("expiry") {
+		month, year, err := card.ParseExpiry(context.String("expiry"))
+		if err != nil {
+			return flags, err
+		}
+		flags.ExpiryMonth = month
+		flags.ExpiryYear = year
+	}
+	if context.IsSet("3ds") {
+		result, err := card.ParseThreeDSResult(context.String("3ds"))
+		if err != nil {
+			return flags, err
+		}
+		flags.ThreeDS = result
+	}
+	return card.ResolveCardDetails(context.String("card"), flags, Environment)
+}
+
+func cardPresetNames() []string {
+	var names []string
+	for name := range card.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
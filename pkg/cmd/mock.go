@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/alphagov/pay-cli/pkg/mock"
+	"github.com/logrusorgru/aurora"
+	"github.com/urfave/cli/v2"
+)
+
+// Mock is the top level command for the local stand-in for GOV.UK Pay
+func Mock() *cli.Command {
+	return &cli.Command{
+		Name:   "mock",
+		Usage:  "Local stand-in for GOV.UK Pay for offline use and testing",
+		Flags:  GlobalFlags,
+		Before: SetGlobalFlags,
+		Subcommands: []*cli.Command{
+			MockServe(),
+		},
+	}
+}
+
+func MockServe() *cli.Command {
+	return &cli.Command{
+		Name:        "serve",
+		Usage:       "Serve the public API and card payment pages from memory",
+		Description: "Payments are kept in memory until the server stops. Point an environment at the server with `pay link`, using its address (including http://) as a custom base URL and any API key",
+		Flags: append(
+			[]cli.Flag{
+				&cli.IntFlag{
+					Name:    "port",
+					Aliases: []string{"p"},
+					Value:   9000,
+					Usage:   "Port to listen on",
+				},
+			},
+			GlobalFlags...,
+		),
+		Before: SetGlobalFlags,
+		Action: runMockServeCmd,
+	}
+}
+
+func runMockServeCmd(context *cli.Context) error {
+	address := fmt.Sprintf("localhost:%d", context.Int("port"))
+	fmt.Printf("> Mock GOV.UK Pay listening on %s\n", aurora.Bold(aurora.Cyan("http://"+address)))
+	fmt.Printf("  Use it with an environment configured with base URL http://%s and any API key, e.g. api_test_mock\n", address)
+	return http.ListenAndServe(address, mock.NewServer())
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Songmu/prompter"
+	"github.com/logrusorgru/aurora"
+	"github.com/urfave/cli/v2"
+)
+
+// confirmLiveEnvironment stops a command that changes payments from running against a live environment
+// unless the user types the environment name or has passed --i-know-this-is-production. Dry runs send
+// nothing that changes a payment so don't need confirming
+func confirmLiveEnvironment(context *cli.Context, action string) error {
+	if !Environment.IsLive() || GetGlobalBool("i-know-this-is-production", context) || context.Bool("dry-run") {
+		return nil
+	}
+	name := Environment.DisplayName()
+
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return err
+	}
+	if (fi.Mode() & os.ModeCharDevice) == 0 {
+		return fmt.Errorf("Refusing to %s in live environment %s without confirmation, pass --i-know-this-is-production to continue", action, name)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s You are about to %s in live environment %s, this will move real money\n", aurora.Red("!"), action, aurora.Bold(name))
+	answer := prompter.Prompt(fmt.Sprintf("Type the environment name (%s) to continue", name), "")
+	if strings.TrimSpace(answer) != name {
+		return fmt.Errorf("Confirmation did not match environment name %s, nothing was changed", name)
+	}
+	return nil
+}
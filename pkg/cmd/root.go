@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/alphagov/pay-cli/pkg/api"
+	"github.com/alphagov/pay-cli/pkg/common"
 	"github.com/alphagov/pay-cli/pkg/config"
 
 	"github.com/urfave/cli/v2"
@@ -28,6 +33,7 @@ func Execute() {
 		CI(),
 		Deployer(),
 		Link(),
+		Mock(),
 		Toolbox(),
 	}
 
@@ -48,15 +54,64 @@ var GlobalFlags = []cli.Flag{
 		Aliases: []string{"e"},
 		Usage:   "environment profile to use with commands",
 	},
+	&cli.IntFlag{
+		Name:  "retries",
+		Value: api.DefaultRetries,
+		Usage: "number of times to retry API requests that fail with a transient error",
+	},
+	&cli.DurationFlag{
+		Name:  "timeout",
+		Value: api.DefaultTimeout,
+		Usage: "timeout for each API request",
+	},
+	&cli.StringFlag{
+		Name:    "output",
+		Aliases: []string{"o"},
+		Usage:   "output format for API resources (json, yaml, table, id, csv, template), defaults to a table or JSON on a terminal and IDs when piped",
+	},
+	&cli.StringFlag{
+		Name:  "template",
+		Usage: "Go template used to output each API resource, e.g. '{{.ID}} {{.State.Status}}'",
+	},
+	&cli.BoolFlag{
+		Name:  "i-know-this-is-production",
+		Usage: "skip the confirmation required before changing payments in a live environment",
+	},
+	&cli.BoolFlag{
+		Name:  "trace",
+		Usage: "log every HTTP request and response to stderr with secrets redacted",
+	},
 }
 
+// SetGlobalFlags records global flags in the app metadata, flags set on a subcommand take
+// precedence over those set higher up and defaults are only recorded once
 func SetGlobalFlags(context *cli.Context) error {
-	if context.IsSet("environment") {
-		context.App.Metadata["environment"] = context.String("environment")
+	for _, flag := range GlobalFlags {
+		name := flag.Names()[0]
+		if _, recorded := context.App.Metadata[name]; !recorded || context.IsSet(name) {
+			context.App.Metadata[name] = globalFlagValue(flag, context)
+		}
 	}
+	common.Trace = GetGlobalBool("trace", context)
 	return nil
 }
 
+// globalFlagValue uses the typed getters as they also look up flags on parent commands, so
+// commands that don't declare GlobalFlags themselves still see the values set higher up
+func globalFlagValue(flag cli.Flag, context *cli.Context) interface{} {
+	name := flag.Names()[0]
+	switch flag.(type) {
+	case *cli.IntFlag:
+		return context.Int(name)
+	case *cli.DurationFlag:
+		return context.Duration(name)
+	case *cli.BoolFlag:
+		return context.Bool(name)
+	default:
+		return context.String(name)
+	}
+}
+
 func GetGlobalFlag(key string, context *cli.Context) string {
 	if result, ok := context.App.Metadata[key].(string); ok {
 		return result
@@ -64,6 +119,27 @@ func GetGlobalFlag(key string, context *cli.Context) string {
 	return ""
 }
 
+func GetGlobalBool(key string, context *cli.Context) bool {
+	if result, ok := context.App.Metadata[key].(bool); ok {
+		return result
+	}
+	return false
+}
+
+func GetGlobalInt(key string, context *cli.Context) int {
+	if result, ok := context.App.Metadata[key].(int); ok {
+		return result
+	}
+	return 0
+}
+
+func GetGlobalDuration(key string, context *cli.Context) time.Duration {
+	if result, ok := context.App.Metadata[key].(time.Duration); ok {
+		return result
+	}
+	return 0
+}
+
 // GetArgOrStdin allows a command to read from either the command arguments if called directly or the standard input if piped
 func GetArgOrStdin(context *cli.Context) (string, error) {
 	var response string
@@ -80,6 +156,11 @@ func GetArgOrStdin(context *cli.Context) (string, error) {
 	} else {
 		response = context.Args().Get(0)
 	}
+	// piped output from list commands is newline terminated
+	response = strings.TrimSpace(response)
+	if lines := strings.Count(response, "\n") + 1; lines > 1 {
+		return "", fmt.Errorf("Expected a single value but got %d lines on standard input, use xargs -n 1 to run the command for each", lines)
+	}
 	return response, nil
 }
 
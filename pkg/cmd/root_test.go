@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/common"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/urfave/cli/v2"
+)
+
+var _ = Describe("Global flags", func() {
+	var app *cli.App
+	var retries int
+	var timeout time.Duration
+	var output string
+	var trace bool
+
+	BeforeEach(func() {
+		record := func(context *cli.Context) error {
+			retries = GetGlobalInt("retries", context)
+			timeout = GetGlobalDuration("timeout", context)
+			output = GetGlobalFlag("output", context)
+			trace = GetGlobalBool("trace", context)
+			return nil
+		}
+		app = cli.NewApp()
+		app.Flags = GlobalFlags
+		app.Before = SetGlobalFlags
+		app.Commands = []*cli.Command{
+			{Name: "global", Flags: GlobalFlags, Before: SetGlobalFlags, Action: record},
+			{Name: "local", Before: SetGlobalFlags, Action: record},
+		}
+	})
+
+	AfterEach(func() {
+		common.Trace = false
+	})
+
+	Specify("Flags set on a subcommand should take precedence", func() {
+		Expect(app.Run([]string{"pay", "--retries", "2", "global", "--retries", "4", "-o", "json"})).Should(Succeed())
+		Expect(retries).Should(Equal(4))
+		Expect(output).Should(Equal("json"))
+	})
+
+	Specify("Commands without GlobalFlags should see the values set before them", func() {
+		Expect(app.Run([]string{"pay", "--retries", "2", "--timeout", "5s", "-o", "id", "--trace", "local"})).Should(Succeed())
+		Expect(retries).Should(Equal(2))
+		Expect(timeout).Should(Equal(5 * time.Second))
+		Expect(output).Should(Equal("id"))
+		Expect(trace).Should(BeTrue())
+		Expect(common.Trace).Should(BeTrue())
+	})
+})
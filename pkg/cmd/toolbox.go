@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"os"
+	"time"
 
 	"github.com/alphagov/pay-cli/pkg/toolbox"
 	"github.com/urfave/cli/v2"
@@ -33,6 +34,11 @@ func Toolbox() *cli.Command {
 					Aliases: []string{"a"},
 					Usage:   "Specify gateway account id as the toolbox entity type",
 				},
+				&cli.DurationFlag{
+					Name:  "wait-timeout",
+					Value: time.Minute,
+					Usage: "How long to wait for a piped payment ID to be found before it is opened",
+				},
 			},
 			GlobalFlags...,
 		),
@@ -43,8 +49,7 @@ func Toolbox() *cli.Command {
 }
 
 func runToolboxCmd(context *cli.Context) error {
-	Environment.Name = GetGlobalFlag("environment", context)
-	err := Environment.Init()
+	client, err := newAPIClient(context)
 	if err != nil {
 		return err
 	}
@@ -56,7 +61,15 @@ func runToolboxCmd(context *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	isInteractive := (fi.Mode() & os.ModeCharDevice) == 0
+	isPiped := (fi.Mode() & os.ModeCharDevice) == 0
 
-	return toolbox.SearchForInput(input, Environment, context, isInteractive)
+	// payments piped from another command may have only just been created, they're opened in any state
+	var waitForPayment func(string) error
+	if isPiped {
+		waitForPayment = func(ID string) error {
+			_, err := client.WaitForPaymentToExist(ID, context.Duration("wait-timeout"))
+			return err
+		}
+	}
+	return toolbox.SearchForInput(input, Environment, context, waitForPayment)
 }
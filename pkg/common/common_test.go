@@ -2,6 +2,7 @@ package common
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -69,4 +70,18 @@ var _ = Describe("Common Helper Tests", func() {
 			Expect(IsCommandAvailable("ls123")).Should(BeFalse())
 		})
 	})
+
+	Context("Checking Percentile returns the nearest-rank percentile", func() {
+		durations := []time.Duration{5, 1, 4, 2, 3, 10, 9, 8, 7, 6}
+
+		Specify("The function should return the expected values for common percentiles", func() {
+			Expect(Percentile(durations, 50)).Should(Equal(time.Duration(5)))
+			Expect(Percentile(durations, 95)).Should(Equal(time.Duration(10)))
+			Expect(Percentile(durations, 0)).Should(Equal(time.Duration(1)))
+		})
+
+		Specify("The function should return zero for no durations", func() {
+			Expect(Percentile(nil, 99)).Should(BeZero())
+		})
+	})
 })
@@ -0,0 +1,144 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Trace is set by the global --trace flag, clients built with TraceTransport log every request when it is on
+var Trace bool
+
+// TraceOutput is where traced requests are written, stderr keeps them out of piped output
+var TraceOutput io.Writer = os.Stderr
+
+const redacted = "[REDACTED]"
+
+const maxTraceBody = 64 * 1024
+
+var redactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Csrf-Token"}
+
+var redactedBodyPatterns = []*regexp.Regexp{
+	// form fields posted to the card frontend
+	regexp.MustCompile(`((?:^|&)(?:csrfToken|cardNo|cvc)=)[^&]*`),
+
+	// hidden CSRF inputs, whichever order the attributes are in
+	regexp.MustCompile(`(?i)(<input[^>]*csrf[^>]*value=")[^"]*`),
+	regexp.MustCompile(`(?i)(<input[^>]*value=")[^"]*("[^>]*csrf)`),
+
+	// JSON fields
+	regexp.MustCompile(`("(?:csrf_?token|card_?number|cvc)"\s*:\s*")[^"]*`),
+}
+
+// card numbers anywhere in a body, including spaced or dashed groups
+var cardNumberPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){11,18}\b`)
+
+// TraceTransport wraps a transport so requests are traced when --trace is on, a nil transport is the
+// default transport
+func TraceTransport(transport http.RoundTripper) http.RoundTripper {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if !Trace {
+		return transport
+	}
+	return &tracingTransport{transport: transport, out: TraceOutput}
+}
+
+// tracingTransport logs the method, URL, status, timing, headers and bodies of each request with secrets redacted
+type tracingTransport struct {
+	transport http.RoundTripper
+	out       io.Writer
+}
+
+func (tracer *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var trace strings.Builder
+	trace.WriteString(fmt.Sprintf("> %s %s\n", req.Method, req.URL))
+	writeHeaders(&trace, ">", req.Header)
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		writeBody(&trace, ">", body)
+	}
+
+	start := time.Now()
+	res, err := tracer.transport.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		trace.WriteString(fmt.Sprintf("< failed after %s: %s\n", elapsed, err))
+		fmt.Fprint(tracer.out, trace.String())
+		return res, err
+	}
+
+	trace.WriteString(fmt.Sprintf("< %s (%s)\n", res.Status, elapsed))
+	writeHeaders(&trace, "<", res.Header)
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return res, err
+	}
+	writeBody(&trace, "<", body)
+	fmt.Fprint(tracer.out, trace.String())
+	return res, nil
+}
+
+func writeHeaders(trace *strings.Builder, prefix string, headers http.Header) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range headers[name] {
+			if isRedactedHeader(name) {
+				value = redacted
+			}
+			trace.WriteString(fmt.Sprintf("%s %s: %s\n", prefix, name, value))
+		}
+	}
+}
+
+func writeBody(trace *strings.Builder, prefix string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	truncated := len(body) > maxTraceBody
+	if truncated {
+		body = body[:maxTraceBody]
+	}
+	for _, line := range strings.Split(strings.TrimRight(RedactBody(string(body)), "\n"), "\n") {
+		trace.WriteString(fmt.Sprintf("%s %s\n", prefix, line))
+	}
+	if truncated {
+		trace.WriteString(fmt.Sprintf("%s (body truncated at %d bytes)\n", prefix, maxTraceBody))
+	}
+}
+
+// RedactBody removes CSRF tokens, card numbers and card security codes from a request or response body
+func RedactBody(body string) string {
+	for _, pattern := range redactedBodyPatterns {
+		body = pattern.ReplaceAllString(body, "${1}"+redacted+"${2}")
+	}
+	return cardNumberPattern.ReplaceAllString(body, redacted)
+}
+
+func isRedactedHeader(name string) bool {
+	for _, header := range redactedHeaders {
+		if strings.EqualFold(name, header) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package common
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tracing HTTP requests", func() {
+	Context("Redacting secrets from bodies", func() {
+		Specify("Card details and CSRF tokens posted to the card frontend should be redacted", func() {
+			body := RedactBody("chargeId=abc&cardNo=4242424242424242&cvc=123&csrfToken=secret-token&email=pay%40cli.gov.uk")
+			Expect(body).Should(Equal("chargeId=abc&cardNo=[REDACTED]&cvc=[REDACTED]&csrfToken=[REDACTED]&email=pay%40cli.gov.uk"))
+		})
+
+		Specify("Hidden CSRF inputs should be redacted whichever order the attributes are in", func() {
+			Expect(RedactBody(`<input id="csrf" name="csrfToken" type="hidden" value="secret-token">`)).Should(Equal(`<input id="csrf" name="csrfToken" type="hidden" value="[REDACTED]">`))
+			Expect(RedactBody(`<input type="hidden" value="secret-token" name="csrfToken">`)).Should(Equal(`<input type="hidden" value="[REDACTED]" name="csrfToken">`))
+		})
+
+		Specify("Card numbers should be redacted wherever they appear", func() {
+			Expect(RedactBody(`{"number": "4444 3333 2222 1111", "last_digits_card_number": "1111"}`)).Should(Equal(`{"number": "[REDACTED]", "last_digits_card_number": "1111"}`))
+		})
+	})
+
+	Context("Logging requests", func() {
+		Specify("Secret headers should be redacted and bodies should still reach the server and caller", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				http.SetCookie(w, &http.Cookie{Name: "frontend_state", Value: "session-secret"})
+				w.Write([]byte("received " + r.PostForm.Get("chargeId")))
+			}))
+			defer server.Close()
+
+			var out bytes.Buffer
+			client := http.Client{Transport: &tracingTransport{transport: http.DefaultTransport, out: &out}}
+			req, _ := http.NewRequest("POST", server.URL, strings.NewReader(url.Values{"chargeId": {"abc"}, "cardNo": {"4242424242424242"}}.Encode()))
+			req.Header.Set("Authorization", "Bearer api_test_secret")
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			res, err := client.Do(req)
+			Expect(err).ShouldNot(HaveOccurred())
+			body, _ := ioutil.ReadAll(res.Body)
+
+			Expect(string(body)).Should(Equal("received abc"))
+			Expect(out.String()).Should(ContainSubstring("> POST " + server.URL))
+			Expect(out.String()).Should(ContainSubstring("< 200 OK"))
+			Expect(out.String()).Should(ContainSubstring("> Authorization: [REDACTED]"))
+			Expect(out.String()).Should(ContainSubstring("< Set-Cookie: [REDACTED]"))
+			Expect(out.String()).Should(ContainSubstring("< received abc"))
+			Expect(out.String()).ShouldNot(ContainSubstring("api_test_secret"))
+			Expect(out.String()).ShouldNot(ContainSubstring("session-secret"))
+			Expect(out.String()).ShouldNot(ContainSubstring("4242424242424242"))
+		})
+	})
+})
@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,6 +10,13 @@ import (
 	"github.com/spf13/viper"
 )
 
+// ProductionBaseURL is the only base URL where payments move real money
+const ProductionBaseURL = "payments.service.gov.uk"
+
+const liveAPIKeyPrefix = "api_live_"
+
+const testAPIKeyPrefix = "api_test_"
+
 // Environment stores all parameters needed to interact with the GOV.UK Pay API
 type Environment struct {
 	Name    string
@@ -40,6 +48,60 @@ func (environment *Environment) Init() error {
 	return nil
 }
 
+// IsLive reports whether the environment can take real money. Live API keys are always live, otherwise
+// production is treated as live unless the key is for a test (sandbox) account
+func (environment *Environment) IsLive() bool {
+	apiKey := strings.TrimSpace(environment.APIKey)
+	if strings.HasPrefix(apiKey, liveAPIKeyPrefix) {
+		return true
+	}
+	baseURL := strings.TrimSuffix(strings.TrimSpace(environment.BaseURL), "/")
+	return baseURL == ProductionBaseURL && !strings.HasPrefix(apiKey, testAPIKeyPrefix)
+}
+
+// PublicAPIURL is the root of the public API, a base URL with an explicit scheme (such as a local mock
+// server) serves every Pay service from the same address
+func (environment *Environment) PublicAPIURL() string {
+	return environment.serviceURL("publicapi")
+}
+
+// FrontendURL is the root of the card payment pages
+func (environment *Environment) FrontendURL() string {
+	return environment.serviceURL("www")
+}
+
+// ToolboxURL is the root of the admin tool used to look up payments
+func (environment *Environment) ToolboxURL() string {
+	return environment.serviceURL("toolbox")
+}
+
+// ReturnURL is where payments created without a return URL send the paying user back to
+func (environment *Environment) ReturnURL() string {
+	if environment.hasScheme() {
+		return strings.TrimSuffix(environment.BaseURL, "/")
+	}
+	return "https://" + environment.BaseURL
+}
+
+func (environment *Environment) serviceURL(subdomain string) string {
+	if environment.hasScheme() {
+		return strings.TrimSuffix(environment.BaseURL, "/")
+	}
+	return fmt.Sprintf("https://%s.%s", subdomain, environment.BaseURL)
+}
+
+func (environment *Environment) hasScheme() bool {
+	return strings.HasPrefix(environment.BaseURL, "http://") || strings.HasPrefix(environment.BaseURL, "https://")
+}
+
+// DisplayName is the name used to select the environment with --environment
+func (environment *Environment) DisplayName() string {
+	if strings.TrimSpace(environment.Name) == "" {
+		return "default"
+	}
+	return environment.Name
+}
+
 func (environment *Environment) GetAPIKey() (string, error) {
 	// if the API key exists on the currently running process
 	if environment.APIKey != "" {
@@ -100,6 +162,12 @@ func makePath(path string) error {
 	return nil
 }
 
+// UnmarshalConfig decodes a parameter from the environment's section of the configuration, such as the
+// [staging.cards] tables, into target. A missing parameter leaves target unchanged
+func (environment *Environment) UnmarshalConfig(param string, target interface{}) error {
+	return viper.UnmarshalKey(environment.GetConfigParam(param), target)
+}
+
 // GetConfigParam returns a namespaced parameter according to the current environment
 func (environment *Environment) GetConfigParam(param string) string {
 	var namespace string
@@ -0,0 +1,41 @@
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Environment", func() {
+	Context("Classifying environments as live or test", func() {
+		Specify("Live API keys should always be live", func() {
+			environment := Environment{APIKey: "api_live_abc", BaseURL: "pymnts.uk"}
+			Expect(environment.IsLive()).Should(BeTrue())
+		})
+
+		Specify("Production should be live unless the API key is for a test account", func() {
+			Expect((&Environment{APIKey: "legacykey", BaseURL: "payments.service.gov.uk"}).IsLive()).Should(BeTrue())
+			Expect((&Environment{APIKey: "api_test_abc", BaseURL: "payments.service.gov.uk"}).IsLive()).Should(BeFalse())
+		})
+
+		Specify("Non-production environments with non-live keys should be test", func() {
+			Expect((&Environment{APIKey: "api_test_abc", BaseURL: "staging.payments.service.gov.uk"}).IsLive()).Should(BeFalse())
+			Expect((&Environment{APIKey: "legacykey", BaseURL: "pymnts.uk"}).IsLive()).Should(BeFalse())
+		})
+	})
+
+	Context("Resolving service URLs", func() {
+		Specify("Each service should have its own subdomain of the base URL", func() {
+			environment := Environment{BaseURL: "pymnts.uk"}
+			Expect(environment.PublicAPIURL()).Should(Equal("https://publicapi.pymnts.uk"))
+			Expect(environment.FrontendURL()).Should(Equal("https://www.pymnts.uk"))
+			Expect(environment.ToolboxURL()).Should(Equal("https://toolbox.pymnts.uk"))
+		})
+
+		Specify("A base URL with a scheme should serve every service", func() {
+			environment := Environment{BaseURL: "http://localhost:9000/"}
+			Expect(environment.PublicAPIURL()).Should(Equal("http://localhost:9000"))
+			Expect(environment.FrontendURL()).Should(Equal("http://localhost:9000"))
+			Expect(environment.ReturnURL()).Should(Equal("http://localhost:9000"))
+		})
+	})
+})
@@ -0,0 +1,266 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/api"
+)
+
+func (server *Server) serveAPI(w http.ResponseWriter, r *http.Request, path []string) {
+	if !strings.HasPrefix(r.Header.Get("authorization"), "Bearer ") || strings.TrimSpace(strings.TrimPrefix(r.Header.Get("authorization"), "Bearer ")) == "" {
+		writeError(w, http.StatusUnauthorized, "P0920", "Credentials are required to access this resource")
+		return
+	}
+
+	switch {
+	case len(path) == 1 && path[0] == "payments" && r.Method == "POST":
+		server.createPayment(w, r)
+	case len(path) == 1 && path[0] == "payments" && r.Method == "GET":
+		server.searchPayments(w, r)
+	case len(path) == 1 && path[0] == "refunds" && r.Method == "GET":
+		server.searchRefunds(w, r)
+	case len(path) >= 2 && path[0] == "payments":
+		record, found := server.payments[path[1]]
+		if !found {
+			writeError(w, http.StatusNotFound, "P0200", fmt.Sprintf("Not found: payment %s", path[1]))
+			return
+		}
+		server.servePayment(w, r, record, path[2:])
+	default:
+		writeError(w, http.StatusNotFound, "P0920", "Not found")
+	}
+}
+
+func (server *Server) servePayment(w http.ResponseWriter, r *http.Request, record *paymentRecord, path []string) {
+	switch {
+	case len(path) == 0 && r.Method == "GET":
+		writeJSON(w, http.StatusOK, record.payment)
+	case len(path) == 1 && path[0] == "events" && r.Method == "GET":
+		writeJSON(w, http.StatusOK, api.PaymentEvents{PaymentID: record.payment.ID, Events: record.events})
+	case len(path) == 1 && path[0] == "capture" && r.Method == "POST":
+		if record.payment.State.Status != "capturable" {
+			writeError(w, http.StatusBadRequest, "P1004", "Charge not available for capture")
+			return
+		}
+		record.capture()
+		w.WriteHeader(http.StatusNoContent)
+	case len(path) == 1 && path[0] == "cancel" && r.Method == "POST":
+		if record.payment.State.Finished {
+			writeError(w, http.StatusBadRequest, "P0502", "Cancellation of payment failed")
+			return
+		}
+		record.finish("cancelled", "P0040", "Payment was cancelled by the service")
+		w.WriteHeader(http.StatusNoContent)
+	case len(path) == 1 && path[0] == "refunds" && r.Method == "POST":
+		server.createRefund(w, r, record)
+	case len(path) == 1 && path[0] == "refunds" && r.Method == "GET":
+		var refunds api.RefundsForPayment
+		refunds.PaymentID = record.payment.ID
+		refunds.Embedded.Refunds = record.refunds
+		if refunds.Embedded.Refunds == nil {
+			refunds.Embedded.Refunds = api.Refunds{}
+		}
+		writeJSON(w, http.StatusOK, refunds)
+	case len(path) == 2 && path[0] == "refunds" && r.Method == "GET":
+		for _, refund := range record.refunds {
+			if refund.ID == path[1] {
+				writeJSON(w, http.StatusOK, refund)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "P0700", fmt.Sprintf("Not found: refund %s", path[1]))
+	default:
+		writeError(w, http.StatusNotFound, "P0920", "Not found")
+	}
+}
+
+func (server *Server) createPayment(w http.ResponseWriter, r *http.Request) {
+	var request api.CreatePaymentRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "P0197", "Unable to parse JSON")
+		return
+	}
+	switch {
+	case request.Amount < 1 || request.Amount > 10000000:
+		writeError(w, http.StatusUnprocessableEntity, "P0102", "Invalid attribute value: amount. Must be greater than or equal to 1")
+		return
+	case strings.TrimSpace(request.Reference) == "":
+		writeError(w, http.StatusBadRequest, "P0101", "Missing mandatory attribute: reference")
+		return
+	case strings.TrimSpace(request.Description) == "":
+		writeError(w, http.StatusBadRequest, "P0101", "Missing mandatory attribute: description")
+		return
+	case request.ReturnURL == "" && request.AuthorisationMode != api.AuthorisationModeAgreement:
+		writeError(w, http.StatusBadRequest, "P0101", "Missing mandatory attribute: return_url")
+		return
+	}
+
+	id := newID()
+	token := newID()
+	base := baseURL(r)
+	language := request.Language
+	if language == "" {
+		language = "en"
+	}
+	record := &paymentRecord{
+		payment: api.Payment{
+			ID:                id,
+			Amount:            request.Amount,
+			Reference:         request.Reference,
+			Description:       request.Description,
+			Language:          language,
+			Email:             request.Email,
+			Metadata:          request.Metadata,
+			CreatedDate:       time.Now().UTC().Format(dateFormat),
+			ReturnURL:         request.ReturnURL,
+			RefundSummary:     api.RefundSummary{Status: "pending"},
+			DelayedCapture:    request.DelayedCapture,
+			Moto:              request.Moto,
+			PaymentProvider:   "sandbox",
+			AuthorisationMode: request.AuthorisationMode,
+			AgreementID:       request.AgreementID,
+			Links: api.PaymentLinks{
+				Self:    api.Link{Href: fmt.Sprintf("%s/v1/payments/%s", base, id), Method: "GET"},
+				NextURL: api.Link{Href: fmt.Sprintf("%s/secure/%s", base, token), Method: "GET"},
+				Events:  api.Link{Href: fmt.Sprintf("%s/v1/payments/%s/events", base, id), Method: "GET"},
+				Refunds: api.Link{Href: fmt.Sprintf("%s/v1/payments/%s/refunds", base, id), Method: "GET"},
+				Cancel:  api.Link{Href: fmt.Sprintf("%s/v1/payments/%s/cancel", base, id), Method: "POST"},
+			},
+		},
+	}
+	if request.PrefilledCardholderDetails != nil {
+		record.payment.CardDetails.CardholderName = request.PrefilledCardholderDetails.CardholderName
+	}
+	record.setState("created", false)
+
+	server.payments[id] = record
+	server.order = append(server.order, id)
+	server.tokens[token] = id
+	writeJSON(w, http.StatusCreated, record.payment)
+}
+
+// searchPayments returns every match on a single page, newest first
+func (server *Server) searchPayments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filters := map[string]func(api.Payment) string{
+		"reference":                func(payment api.Payment) string { return payment.Reference },
+		"email":                    func(payment api.Payment) string { return payment.Email },
+		"state":                    func(payment api.Payment) string { return payment.State.Status },
+		"card_brand":               func(payment api.Payment) string { return payment.CardDetails.CardBrand },
+		"last_digits_card_number":  func(payment api.Payment) string { return payment.CardDetails.LastDigitsCardNumber },
+		"first_digits_card_number": func(payment api.Payment) string { return payment.CardDetails.FirstDigitsCardNumber },
+		"cardholder_name":          func(payment api.Payment) string { return payment.CardDetails.CardholderName },
+	}
+
+	results := api.PaymentSearchResults{Page: 1, Results: []api.Payment{}}
+	for index := len(server.order) - 1; index >= 0; index-- {
+		payment := server.payments[server.order[index]].payment
+		matches := true
+		for name, field := range filters {
+			if value := query.Get(name); value != "" && !strings.EqualFold(field(payment), value) {
+				matches = false
+			}
+		}
+		if matches {
+			results.Results = append(results.Results, payment)
+		}
+	}
+	results.Total = len(results.Results)
+	results.Count = len(results.Results)
+	results.Links.Self = api.Link{Href: baseURL(r) + r.URL.RequestURI(), Method: "GET"}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (server *Server) searchRefunds(w http.ResponseWriter, r *http.Request) {
+	results := api.RefundSearchResults{Page: 1, Results: api.Refunds{}}
+	for index := len(server.order) - 1; index >= 0; index-- {
+		results.Results = append(results.Results, server.payments[server.order[index]].refunds...)
+	}
+	results.Total = len(results.Results)
+	results.Count = len(results.Results)
+	results.Links.Self = api.Link{Href: baseURL(r) + r.URL.RequestURI(), Method: "GET"}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// createRefund succeeds straight away, there is no payment provider to wait for
+func (server *Server) createRefund(w http.ResponseWriter, r *http.Request, record *paymentRecord) {
+	var request api.RefundPaymentRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "P0602", "Unable to parse JSON")
+		return
+	}
+	summary := &record.payment.RefundSummary
+	if summary.Status != "available" {
+		writeError(w, http.StatusBadRequest, "P0603", "The payment is not available for refund. Payment refund status: "+summary.Status)
+		return
+	}
+	if request.RefundAmountAvailable != nil && *request.RefundAmountAvailable != summary.AmountAvailable {
+		writeError(w, http.StatusPreconditionFailed, "P0604", "Refund amount available mismatch")
+		return
+	}
+	if request.Amount < 1 || request.Amount > summary.AmountAvailable {
+		writeError(w, http.StatusBadRequest, "P0602", "Invalid attribute value: amount")
+		return
+	}
+
+	summary.AmountAvailable -= request.Amount
+	summary.AmountSubmitted += request.Amount
+	if summary.AmountAvailable == 0 {
+		summary.Status = "full"
+	}
+
+	id := newID()
+	base := baseURL(r)
+	refund := api.Refund{
+		ID:          id,
+		PaymentID:   record.payment.ID,
+		CreatedDate: time.Now().UTC().Format(dateFormat),
+		Amount:      request.Amount,
+		Status:      "success",
+		Links: api.RefundLinks{
+			Self:    api.Link{Href: fmt.Sprintf("%s/v1/payments/%s/refunds/%s", base, record.payment.ID, id), Method: "GET"},
+			Payment: api.Link{Href: fmt.Sprintf("%s/v1/payments/%s", base, record.payment.ID), Method: "GET"},
+		},
+	}
+	record.refunds = append(record.refunds, refund)
+	writeJSON(w, http.StatusAccepted, refund)
+}
+
+// authorise is called when the paying user confirms, delayed capture payments wait to be captured
+func (record *paymentRecord) authorise() {
+	if record.payment.DelayedCapture {
+		record.setState("capturable", false)
+		record.payment.Links.Capture = api.Link{Href: record.payment.Links.Self.Href + "/capture", Method: "POST"}
+		return
+	}
+	record.capture()
+}
+
+// finish ends a payment that was never taken
+func (record *paymentRecord) finish(status string, code string, message string) {
+	record.payment.State = api.PaymentState{Status: status, Finished: true, Code: code, Message: message}
+	record.events = append(record.events, api.PaymentEvent{
+		PaymentID: record.payment.ID,
+		State:     record.payment.State,
+		Updated:   time.Now().UTC(),
+	})
+	record.payment.RefundSummary.Status = "unavailable"
+	record.payment.Links.Cancel = api.Link{}
+}
+
+func (record *paymentRecord) capture() {
+	record.setState("success", true)
+	record.payment.Links.Capture = api.Link{}
+	record.payment.RefundSummary = api.RefundSummary{Status: "available", AmountAvailable: record.payment.Amount}
+	now := time.Now().UTC()
+	record.payment.SettlementSummary = api.SettlementSummary{
+		CaptureSubmitTime: now.Format(dateFormat),
+		CapturedDate:      now.Format("2006-01-02"),
+	}
+}
@@ -0,0 +1,286 @@
+package mock
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/api"
+)
+
+const sessionCookie = "frontend_state"
+
+// declinedCards are the sandbox numbers that are refused, errorCard fails as if the provider was down
+var declinedCards = map[string]bool{
+	"4000000000000002": true,
+	"4000000000000069": true,
+	"4000000000000127": true,
+}
+
+const errorCard = "4000000000000119"
+
+const declinedMessage = "Your payment has been declined"
+
+const cancelledMessage = "Your payment has been cancelled"
+
+const errorMessage = "Sorry, we’re experiencing technical problems"
+
+var cardDetailsPage = template.Must(template.New("card_details").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><title>Enter card details - GOV.UK Pay</title></head>
+<body>
+<h1>Enter card details</h1>
+<p>{{.Payment.Description}}, total amount £{{.Amount}}</p>
+{{if .Error}}<div class="govuk-error-summary" id="error-summary"><p>{{.Error}}</p></div>{{end}}
+<form id="card-details" method="POST" action="/card_details/{{.Payment.ID}}">
+  <input id="csrf" name="csrfToken" type="hidden" value="{{.CSRF}}">
+  <input id="charge-id" name="chargeId" type="hidden" value="{{.Payment.ID}}">
+  <input id="card-no" name="cardNo" type="text">
+  <input id="expiry-month" name="expiryMonth" type="text">
+  <input id="expiry-year" name="expiryYear" type="text">
+  <input id="cardholder-name" name="cardholderName" type="text">
+  <input id="cvc" name="cvc" type="text">
+  <input id="address-line-1" name="addressLine1" type="text">
+  <input id="address-city" name="addressCity" type="text">
+  <input id="address-country" name="addressCountry" type="text">
+  <input id="address-postcode" name="addressPostcode" type="text">
+  <input id="email" name="email" type="text">
+  <button type="submit" id="submit-card-details">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+var confirmPage = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><title>Confirm your payment - GOV.UK Pay</title></head>
+<body>
+<h1>Confirm your payment</h1>
+<table>
+  <tr><th>Card number</th><td id="card-number">●●●●●●●●●●●●{{.Payment.CardDetails.LastDigitsCardNumber}}</td></tr>
+  <tr><th>Name on card</th><td id="cardholder-name">{{.Payment.CardDetails.CardholderName}}</td></tr>
+  <tr><th>Total amount</th><td id="amount">£{{.Amount}}</td></tr>
+</table>
+<form id="confirmation" method="POST" action="/card_details/{{.Payment.ID}}/confirm">
+  <input id="csrf" name="csrfToken" type="hidden" value="{{.CSRF}}">
+  <input id="charge-id" name="chargeId" type="hidden" value="{{.Payment.ID}}">
+  <button type="submit" id="confirm">Confirm payment</button>
+</form>
+</body>
+</html>
+`))
+
+var messagePage = template.Must(template.New("message").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><title>{{.}} - GOV.UK Pay</title></head>
+<body><h1 id="message">{{.}}</h1></body>
+</html>
+`))
+
+type pageData struct {
+	Payment api.Payment
+	Amount  string
+	CSRF    string
+	Error   string
+}
+
+// serveNextURL starts the paying user's session and sends them on to the card details page
+func (server *Server) serveNextURL(w http.ResponseWriter, r *http.Request, token string) {
+	id, found := server.tokens[token]
+	if !found {
+		writeMessage(w, http.StatusNotFound, "This page cannot be found")
+		return
+	}
+	record := server.payments[id]
+	if record.payment.State.Status == "cancelled" {
+		writeMessage(w, http.StatusOK, cancelledMessage)
+		return
+	}
+	if record.payment.State.Status != "created" {
+		writeMessage(w, http.StatusBadRequest, "Your payment session has expired")
+		return
+	}
+	record.setState("started", false)
+	record.session = newID()
+	record.csrf = newID()
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: record.session, Path: "/", HttpOnly: true})
+	http.Redirect(w, r, "/card_details/"+id, http.StatusSeeOther)
+}
+
+func (server *Server) serveCardDetails(w http.ResponseWriter, r *http.Request, id string, path []string) {
+	record, found := server.payments[id]
+	if !found {
+		writeMessage(w, http.StatusNotFound, "This page cannot be found")
+		return
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil || record.session == "" || cookie.Value != record.session {
+		writeMessage(w, http.StatusForbidden, "There is a problem with your session")
+		return
+	}
+	// the issuer posts the challenge result back without the frontend's CSRF token
+	threeDSReturn := len(path) == 1 && path[0] == "3ds_required_in"
+	if r.Method == "POST" && !threeDSReturn && r.PostFormValue("csrfToken") != record.csrf {
+		writeMessage(w, http.StatusForbidden, "There is a problem with your session")
+		return
+	}
+
+	if record.payment.State.Status == "cancelled" {
+		writeMessage(w, http.StatusOK, cancelledMessage)
+		return
+	}
+
+	switch {
+	case len(path) == 0 && r.Method == "GET":
+		writePage(w, http.StatusOK, cardDetailsPage, record, "")
+	case len(path) == 0 && r.Method == "POST":
+		server.postCardDetails(w, r, record)
+	case len(path) == 1 && path[0] == "3ds_required" && r.Method == "GET":
+		server.serveThreeDSRequired(w, record)
+	case threeDSReturn && r.Method == "POST":
+		server.postThreeDSResult(w, r, record)
+	case len(path) == 1 && path[0] == "auth_waiting" && r.Method == "GET":
+		server.serveAuthWaiting(w, r, record)
+	case len(path) == 1 && path[0] == "confirm" && r.Method == "GET":
+		if record.payment.State.Status != "submitted" {
+			writeMessage(w, http.StatusBadRequest, "Your payment session has expired")
+			return
+		}
+		writePage(w, http.StatusOK, confirmPage, record, "")
+	case len(path) == 1 && path[0] == "confirm" && r.Method == "POST":
+		if record.payment.State.Status != "submitted" {
+			writeMessage(w, http.StatusBadRequest, "Your payment session has expired")
+			return
+		}
+		record.authorise()
+		http.Redirect(w, r, record.payment.ReturnURL, http.StatusSeeOther)
+	default:
+		writeMessage(w, http.StatusNotFound, "This page cannot be found")
+	}
+}
+
+// postCardDetails re-renders the card details page if the card is invalid, as the real frontend does
+func (server *Server) postCardDetails(w http.ResponseWriter, r *http.Request, record *paymentRecord) {
+	if record.payment.State.Status != "started" {
+		writeMessage(w, http.StatusBadRequest, "Your payment session has expired")
+		return
+	}
+	cardNumber := strings.Replace(r.PostFormValue("cardNo"), " ", "", -1)
+	message := validateCardDetails(cardNumber, r.PostFormValue("expiryMonth"), r.PostFormValue("expiryYear"), r.PostFormValue("cvc"))
+	if message != "" {
+		writePage(w, http.StatusOK, cardDetailsPage, record, message)
+		return
+	}
+
+	details := &record.payment.CardDetails
+	details.FirstDigitsCardNumber = cardNumber[:6]
+	details.LastDigitsCardNumber = cardNumber[len(cardNumber)-4:]
+	details.CardBrand = cardBrand(cardNumber)
+	details.CardType = "debit"
+	details.CardholderName = r.PostFormValue("cardholderName")
+	details.ExpiryDate = r.PostFormValue("expiryMonth") + "/" + lastTwo(r.PostFormValue("expiryYear"))
+	details.BillingAddress.Line1 = r.PostFormValue("addressLine1")
+	details.BillingAddress.City = r.PostFormValue("addressCity")
+	details.BillingAddress.Country = r.PostFormValue("addressCountry")
+	details.BillingAddress.Postcode = r.PostFormValue("addressPostcode")
+	if email := r.PostFormValue("email"); email != "" {
+		record.payment.Email = email
+	}
+
+	switch {
+	case declinedCards[cardNumber]:
+		record.finish("failed", "P0010", "Payment method rejected")
+		writeMessage(w, http.StatusOK, declinedMessage)
+	case cardNumber == errorCard:
+		record.finish("error", "P0050", "Payment provider returned an error")
+		writeMessage(w, http.StatusInternalServerError, errorMessage)
+	case challengeCards[cardNumber]:
+		record.challenged = true
+		http.Redirect(w, r, "/card_details/"+record.payment.ID+"/3ds_required", http.StatusSeeOther)
+	default:
+		record.setState("submitted", false)
+		http.Redirect(w, r, "/card_details/"+record.payment.ID+"/confirm", http.StatusSeeOther)
+	}
+}
+
+// validateCardDetails returns the error shown to the paying user, if there is one
+func validateCardDetails(cardNumber string, expiryMonth string, expiryYear string, cvc string) string {
+	if len(cardNumber) < 12 || !luhnValid(cardNumber) {
+		return "Enter a valid card number"
+	}
+	month, monthErr := strconv.Atoi(expiryMonth)
+	year, yearErr := strconv.Atoi(expiryYear)
+	if len(expiryYear) == 2 {
+		year += 2000
+	}
+	now := time.Now()
+	if monthErr != nil || yearErr != nil || month < 1 || month > 12 || year < now.Year() || (year == now.Year() && time.Month(month) < now.Month()) {
+		return "Enter a valid expiry date"
+	}
+	if _, err := strconv.Atoi(cvc); err != nil || len(cvc) < 3 || len(cvc) > 4 {
+		return "Enter a valid card security code"
+	}
+	return ""
+}
+
+func writePage(w http.ResponseWriter, status int, page *template.Template, record *paymentRecord, message string) {
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	page.Execute(w, pageData{
+		Payment: record.payment,
+		Amount:  fmt.Sprintf("%.2f", float64(record.payment.Amount)/100),
+		CSRF:    record.csrf,
+		Error:   message,
+	})
+}
+
+func writeMessage(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	messagePage.Execute(w, message)
+}
+
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for index := len(number) - 1; index >= 0; index-- {
+		digit := int(number[index] - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func cardBrand(number string) string {
+	switch {
+	case strings.HasPrefix(number, "34") || strings.HasPrefix(number, "37"):
+		return "american-express"
+	case strings.HasPrefix(number, "4"):
+		return "visa"
+	case number[0] == '5' && number[1] >= '1' && number[1] <= '5', strings.HasPrefix(number, "2"):
+		return "master-card"
+	case strings.HasPrefix(number, "6"), strings.HasPrefix(number, "50"):
+		return "maestro"
+	default:
+		return "unknown"
+	}
+}
+
+func lastTwo(value string) string {
+	if len(value) > 2 {
+		return value[len(value)-2:]
+	}
+	return value
+}
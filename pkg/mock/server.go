@@ -0,0 +1,112 @@
+package mock
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alphagov/pay-cli/pkg/api"
+)
+
+const dateFormat = "2006-01-02T15:04:05.000Z"
+
+const idAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// Server is a local stand-in for GOV.UK Pay, it serves the public API and the card payment pages from
+// one address with state held in memory. Point an environment at it with a base URL such as
+// http://localhost:9000
+type Server struct {
+	mutex    sync.Mutex
+	payments map[string]*paymentRecord
+	order    []string
+	tokens   map[string]string
+}
+
+// paymentRecord is everything the server knows about a payment, including what only the frontend sees
+type paymentRecord struct {
+	payment api.Payment
+	events  []api.PaymentEvent
+	refunds api.Refunds
+
+	// the paying user's session, set when the next url is visited
+	session string
+	csrf    string
+
+	// set while a 3-D Secure challenge is waiting for the issuer's answer
+	challenged  bool
+	authWaiting bool
+}
+
+// NewServer returns an empty server, use it as an http.Handler
+func NewServer() *Server {
+	return &Server{
+		payments: make(map[string]*paymentRecord),
+		tokens:   make(map[string]string),
+	}
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case path[0] == "v1":
+		server.serveAPI(w, r, path[1:])
+	case path[0] == "secure" && len(path) == 2 && r.Method == "GET":
+		server.serveNextURL(w, r, path[1])
+	case path[0] == "card_details" && len(path) >= 2:
+		server.serveCardDetails(w, r, path[1], path[2:])
+	case path[0] == "acs" && len(path) >= 2 && r.Method == "POST":
+		server.serveACS(w, r, path[1], path[2:])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// setState moves a payment to a new state and records the transition as an event
+func (record *paymentRecord) setState(status string, finished bool) {
+	record.payment.State = api.PaymentState{Status: status, Finished: finished}
+	record.events = append(record.events, api.PaymentEvent{
+		PaymentID: record.payment.ID,
+		State:     record.payment.State,
+		Updated:   time.Now().UTC(),
+	})
+}
+
+// baseURL is the address the request was made to, links point back to the same server
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// newID returns a random ID in the same format as Pay external IDs
+func newID() string {
+	var builder strings.Builder
+	max := big.NewInt(int64(len(idAlphabet)))
+	for index := 0; index < 26; index++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		builder.WriteByte(idAlphabet[n.Int64()])
+	}
+	return builder.String()
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, description string) {
+	writeJSON(w, status, api.Error{Code: code, Description: description})
+}
@@ -0,0 +1,141 @@
+package mock
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// challengeCards are the Stripe test numbers that are asked for 3-D Secure, the issuer's challenge is
+// served from /acs on the same server
+var challengeCards = map[string]bool{
+	"4000000000003220": true,
+	"4000000000003063": true,
+}
+
+// the challenge result carried back to the frontend, as transStatus in a 3DS2 CRes
+const (
+	challengePassed = "Y"
+	challengeFailed = "N"
+)
+
+// threeDSRequiredPage would be submitted by JavaScript in a browser, it hands the challenge to the issuer
+var threeDSRequiredPage = template.Must(template.New("3ds_required").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><title>Your payment is being authenticated - GOV.UK Pay</title></head>
+<body>
+<h1>Your payment is being authenticated</h1>
+<form id="threeDsForm" method="POST" action="/acs/{{.Payment.ID}}">
+  <input name="creq" type="hidden" value="{{.Payment.ID}}">
+  <input name="threeDSSessionData" type="hidden" value="{{.CSRF}}">
+  <noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+var challengePage = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><title>Purchase authentication</title></head>
+<body>
+<h2>Purchase authentication</h2>
+<form id="challenge" method="POST" action="/acs/{{.ID}}/challenge">
+  <input name="threeDSSessionData" type="hidden" value="{{.Session}}">
+  <select name="challengeResult">
+    <option value="pass">Authenticated</option>
+    <option value="fail">Not authenticated</option>
+  </select>
+  <button type="submit">Submit</button>
+</form>
+</body>
+</html>
+`))
+
+var challengeResultPage = template.Must(template.New("challenge_result").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><title>Purchase authentication</title></head>
+<body>
+<form id="challengeResult" method="POST" action="/card_details/{{.ID}}/3ds_required_in">
+  <input name="cres" type="hidden" value="{{.Result}}">
+  <input name="threeDSSessionData" type="hidden" value="{{.Session}}">
+  <noscript><button type="submit">Continue</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+var authWaitingPage = template.Must(template.New("auth_waiting").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><title>Your payment is being processed - GOV.UK Pay</title><meta http-equiv="refresh" content="1"></head>
+<body><h1>Your payment is being processed</h1></body>
+</html>
+`))
+
+type challengeData struct {
+	ID      string
+	Session string
+	Result  string
+}
+
+func (server *Server) serveThreeDSRequired(w http.ResponseWriter, record *paymentRecord) {
+	if !record.challenged {
+		writeMessage(w, http.StatusBadRequest, "Your payment session has expired")
+		return
+	}
+	writePage(w, http.StatusOK, threeDSRequiredPage, record, "")
+}
+
+// serveACS stands in for the card issuer's access control server, which shows the challenge
+func (server *Server) serveACS(w http.ResponseWriter, r *http.Request, id string, path []string) {
+	record, found := server.payments[id]
+	if !found || !record.challenged || r.PostFormValue("threeDSSessionData") != record.csrf {
+		writeMessage(w, http.StatusBadRequest, "Invalid authentication request")
+		return
+	}
+	data := challengeData{ID: id, Session: record.csrf}
+
+	switch {
+	case len(path) == 0:
+		writeTemplate(w, challengePage, data)
+	case len(path) == 1 && path[0] == "challenge":
+		data.Result = challengeFailed
+		if r.PostFormValue("challengeResult") == "pass" {
+			data.Result = challengePassed
+		}
+		writeTemplate(w, challengeResultPage, data)
+	default:
+		writeMessage(w, http.StatusNotFound, "This page cannot be found")
+	}
+}
+
+// postThreeDSResult receives the issuer's answer, authenticated payments wait to be authorised
+func (server *Server) postThreeDSResult(w http.ResponseWriter, r *http.Request, record *paymentRecord) {
+	if !record.challenged || r.PostFormValue("threeDSSessionData") != record.csrf {
+		writeMessage(w, http.StatusBadRequest, "Your payment session has expired")
+		return
+	}
+	record.challenged = false
+	if r.PostFormValue("cres") != challengePassed {
+		record.finish("failed", "P0010", "Payment method rejected")
+		writeMessage(w, http.StatusOK, declinedMessage)
+		return
+	}
+	record.setState("submitted", false)
+	record.authWaiting = true
+	http.Redirect(w, r, "/card_details/"+record.payment.ID+"/auth_waiting", http.StatusSeeOther)
+}
+
+// serveAuthWaiting shows the waiting page once before the confirm page, as a slow provider would
+func (server *Server) serveAuthWaiting(w http.ResponseWriter, r *http.Request, record *paymentRecord) {
+	if record.authWaiting {
+		record.authWaiting = false
+		writeTemplate(w, authWaitingPage, nil)
+		return
+	}
+	http.Redirect(w, r, "/card_details/"+record.payment.ID+"/confirm", http.StatusSeeOther)
+}
+
+func writeTemplate(w http.ResponseWriter, page *template.Template, data interface{}) {
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	page.Execute(w, data)
+}
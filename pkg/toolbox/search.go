@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/alphagov/pay-cli/pkg/config"
 	// @TODO(sfount) move the libs for spinners out of card package
@@ -14,7 +13,9 @@ import (
 	"github.com/urfave/cli/v2"
 )
 
-func SearchForInput(input string, environment config.Environment, context *cli.Context, isInteractive bool) error {
+// SearchForInput opens the best match for input in Toolbox, waitForPayment is called first for payment IDs
+// when it is set so that piped payments can be found before they are opened
+func SearchForInput(input string, environment config.Environment, context *cli.Context, waitForPayment func(string) error) error {
 	if strings.TrimSpace(input) == "" {
 		return errors.New("Search term is required to open Toolbox, see `help` for valid search entities")
 	}
@@ -28,16 +29,20 @@ func SearchForInput(input string, environment config.Environment, context *cli.C
 		return nil
 	}
 
-	if isInteractive {
-		s := card.StartProgress("Got interactive input, delaying for Ledger")
-		time.Sleep(1000 * time.Millisecond)
-		s.Stop()
+	if waitForPayment != nil && matchedFeature == TRANSACTION_ID {
+		s := card.StartProgress("Got piped input, waiting for the payment to be available")
+		err := waitForPayment(input)
+		if err != nil {
+			card.ProgressFail(s)
+			return err
+		}
+		card.ProgressSuccess(s)
 	}
 	return openFeature(matchedFeature, input, environment)
 }
 
 func openFeature(matchedFeature Feature, input string, environment config.Environment) error {
-	toolboxBaseURL := fmt.Sprintf("https://toolbox.%s", environment.BaseURL)
+	toolboxBaseURL := environment.ToolboxURL()
 	switch matchedFeature {
 	case TRANSACTION_ID:
 		browser.OpenURL(fmt.Sprintf("%s/transactions/%s", toolboxBaseURL, input))